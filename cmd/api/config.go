@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"github.com/agalitsyn/go-app/internal/pkg/flag"
+)
+
+type ConfigCommand struct {
+	Print struct{} `command:"print" description:"Print effective configuration as YAML, secrets are redacted."`
+}
+
+// Validate checks values which parser can't, e.g. formats and constraints between options.
+func (c *CliFlags) Validate() error {
+	var v flag.Validator
+
+	v.Addr("--addr", c.HTTP.Addr)
+	if c.HTTP.AdminAddr != "" {
+		v.Addr("--admin-addr", c.HTTP.AdminAddr)
+		v.Check(c.HTTP.AdminAddr != c.HTTP.Addr, "--admin-addr", "must differ from --addr")
+	}
+	for _, origin := range c.HTTP.AllowedOrigins {
+		v.Origin("--allowed-origins", origin)
+	}
+	for _, header := range append(c.HTTP.AllowedHeaders, c.HTTP.ExposedHeaders...) {
+		v.Check(header != "" && !strings.ContainsAny(header, " \t,:"), "--allowed-headers, --exposed-headers", "invalid header name %q", header)
+	}
+
+	v.Duration("--read-timeout", c.HTTP.ReadTimeout, 0, 0)
+	v.Duration("--write-timeout", c.HTTP.WriteTimeout, 0, 0)
+	v.Duration("--idle-timeout", c.HTTP.IdleTimeout, 0, 0)
+	v.Duration("--request-timeout", c.HTTP.RequestTimeout, 0, 0)
+	// Otherwise connection is closed before timeout response is written.
+	v.Check(c.HTTP.WriteTimeout == 0 || c.HTTP.RequestTimeout == 0 || c.HTTP.RequestTimeout < c.HTTP.WriteTimeout,
+		"--request-timeout", "must be less than --write-timeout")
+	v.Check(c.HTTP.MaxInFlight >= 0, "--max-in-flight", "must not be negative")
+	v.Check(c.HTTP.MinInFlight >= 1, "--min-in-flight", "must be at least 1")
+	v.Duration("--max-pool-wait", c.HTTP.MaxPoolWait, time.Millisecond, 0)
+	v.Check(c.Cache.Size >= 0, "--cache-size", "must not be negative")
+	if c.Cache.Size > 0 {
+		v.Duration("--cache-ttl", c.Cache.TTL, time.Second, 0)
+	}
+	if c.Storage == storageSQLite {
+		// Query of the path would be taken as connection parameters.
+		v.Check(c.SQLite.Path != "" && !strings.Contains(c.SQLite.Path, "?"), "--sqlite-path", "must be a file path without ?")
+	}
+	v.Duration("--startup-timeout", c.StartupTimeout, time.Second, 0)
+	v.Duration("--shutdown-delay", c.HTTP.ShutdownDelay, 0, 5*time.Minute)
+	v.Duration("--shutdown-timeout", c.HTTP.ShutdownTimeout, time.Second, 0)
+	v.Check(c.HTTP.RateLimit >= 0, "--rate-limit", "must not be negative")
+	v.Check(c.HTTP.RateBurst >= 1, "--rate-burst", "must be at least 1")
+
+	if strings.Contains(c.Postgres.URL, "://") {
+		v.URL("--postgres-url", c.Postgres.URL, "postgres", "postgresql")
+	}
+	if _, err := pgxpool.ParseConfig(c.Postgres.URL); err != nil {
+		v.Check(false, "--postgres-url", "%s", err)
+	}
+	for _, replicaURL := range c.Postgres.ReplicaURLs {
+		if strings.Contains(replicaURL, "://") {
+			v.URL("--postgres-replica-url", replicaURL, "postgres", "postgresql")
+		}
+		if _, err := pgxpool.ParseConfig(replicaURL); err != nil {
+			v.Check(false, "--postgres-replica-url", "%s", err)
+		}
+	}
+	v.Check(c.Postgres.MaxConns >= 0, "--postgres-max-conns", "must not be negative")
+	v.Check(c.Postgres.MinConns >= 0, "--postgres-min-conns", "must not be negative")
+	v.Check(c.Postgres.MaxConns == 0 || c.Postgres.MinConns <= c.Postgres.MaxConns, "--postgres-min-conns", "must not exceed --postgres-max-conns")
+	v.Duration("--postgres-max-conn-lifetime", c.Postgres.MaxConnLifetime, 0, 0)
+	v.Duration("--postgres-max-conn-idle-time", c.Postgres.MaxConnIdleTime, 0, 0)
+	v.Duration("--postgres-health-check-period", c.Postgres.HealthCheckPeriod, 0, 0)
+	v.Duration("--postgres-connect-timeout", c.Postgres.ConnectTimeout, 0, 0)
+	v.Duration("--postgres-statement-timeout", c.Postgres.StatementTimeout, 0, 0)
+	v.Check(c.Postgres.ConnectAttempts >= 0, "--postgres-connect-attempts", "must not be negative")
+	v.Duration("--postgres-slow-query", c.Postgres.SlowQuery, 0, 0)
+	v.Check(c.Postgres.BreakerFailureRatio >= 0 && c.Postgres.BreakerFailureRatio <= 1, "--postgres-breaker-failure-ratio", "must be between 0 and 1")
+	if c.Postgres.BreakerFailureRatio > 0 {
+		v.Check(c.Postgres.BreakerMinRequests >= 1, "--postgres-breaker-min-requests", "must be at least 1")
+		v.Duration("--postgres-breaker-window", c.Postgres.BreakerWindow, time.Second, 0)
+		v.Duration("--postgres-breaker-open-timeout", c.Postgres.BreakerOpenTimeout, 100*time.Millisecond, 0)
+		v.Check(c.Postgres.BreakerProbes >= 1, "--postgres-breaker-probes", "must be at least 1")
+	}
+	v.Check(c.Postgres.TxAttempts >= 1, "--postgres-tx-attempts", "must be at least 1")
+	v.Duration("--postgres-monitor-interval", c.Postgres.MonitorInterval, 100*time.Millisecond, 0)
+	v.Check(c.Postgres.MonitorThreshold >= 1, "--postgres-monitor-threshold", "must be at least 1")
+
+	return v.Err()
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/agalitsyn/go-app/internal/pkg/slug"
+	"github.com/agalitsyn/go-app/internal/storage"
+)
+
+const dataBatchSize = 500
+
+type SeedCommand struct{}
+
+type ExportCommand struct {
+	File string `long:"file" short:"f" default:"-" description:"File to write articles to as JSON lines, - means stdout."`
+}
+
+type ImportCommand struct {
+	File string `long:"file" short:"f" default:"-" description:"File to read articles from as JSON lines, - means stdin."`
+}
+
+// articleRecord is a line of export and import files.
+type articleRecord struct {
+	ID    int    `json:"id,omitempty"`
+	Title string `json:"title"`
+	Slug  string `json:"slug"`
+}
+
+var seedTitles = []string{
+	"Getting started with Go",
+	"Effective Go",
+	"Go concurrency patterns",
+	"Error handling in Go",
+	"Testing in Go",
+}
+
+// runSeed stores demo articles, existing articles with the same slugs are updated.
+func runSeed(ctx context.Context, store storage.ArticleRepository) error {
+	articles := seedArticles()
+	if err := store.StoreArticles(ctx, articles); err != nil {
+		return fmt.Errorf("could not store articles: %w", err)
+	}
+	fmt.Fprintf(os.Stdout, "seeded %d articles\n", len(articles))
+	return nil
+}
+
+func seedArticles() []storage.Article {
+	articles := make([]storage.Article, 0, len(seedTitles))
+	for _, title := range seedTitles {
+		articles = append(articles, storage.Article{Title: title, Slug: slug.Make(title)})
+	}
+	return articles
+}
+
+func runExport(ctx context.Context, cmd ExportCommand, store storage.ArticleRepository) error {
+	out := os.Stdout
+	if cmd.File != "-" {
+		f, err := os.Create(cmd.File)
+		if err != nil {
+			return fmt.Errorf("could not create file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	var total int
+	for offset := uint64(0); ; offset += dataBatchSize {
+		articles, err := store.FilterArticles(ctx, storage.ArticleFilter{Limit: dataBatchSize, Offset: offset})
+		if err != nil {
+			return fmt.Errorf("could not fetch articles: %w", err)
+		}
+		for _, article := range articles {
+			if err = enc.Encode(articleRecord{ID: article.ID, Title: article.Title, Slug: article.Slug}); err != nil {
+				return fmt.Errorf("could not write article: %w", err)
+			}
+		}
+		total += len(articles)
+		if len(articles) < dataBatchSize {
+			break
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("could not write articles: %w", err)
+	}
+	if out == os.Stdout {
+		return nil
+	}
+	fmt.Fprintf(os.Stdout, "exported %d articles\n", total)
+	return out.Close()
+}
+
+// runImport stores articles from JSON lines in batches. Articles are matched by slug,
+// so importing the same file twice updates titles instead of creating duplicates.
+func runImport(ctx context.Context, cmd ImportCommand, store storage.ArticleRepository) error {
+	in := io.ReadCloser(os.Stdin)
+	if cmd.File != "-" {
+		f, err := os.Open(cmd.File)
+		if err != nil {
+			return fmt.Errorf("could not open file: %w", err)
+		}
+		in = f
+	}
+	defer in.Close()
+
+	var (
+		total int
+		batch = make([]storage.Article, 0, dataBatchSize)
+	)
+	flush := func() error {
+		if err := store.StoreArticles(ctx, batch); err != nil {
+			return fmt.Errorf("could not store articles: %w", err)
+		}
+		total += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(in))
+	for line := 1; ; line++ {
+		var rec articleRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("could not decode record %d: %w", line, err)
+		}
+		if rec.Title == "" || rec.Slug == "" {
+			return fmt.Errorf("record %d: title and slug are required", line)
+		}
+
+		batch = append(batch, storage.Article{Title: rec.Title, Slug: rec.Slug})
+		if len(batch) == dataBatchSize {
+			if err = flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "imported %d articles\n", total)
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/agalitsyn/go-app/internal/pkg/postgres"
+)
+
+type MigrateCommand struct {
+	Up     struct{} `command:"up" description:"Apply all pending migrations."`
+	Down   struct{} `command:"down" description:"Rollback the last applied migration."`
+	Status struct{} `command:"status" description:"Show current version and pending migrations."`
+	To     struct {
+		Args struct {
+			Version int32 `positional-arg-name:"N" description:"Target version, 0 rollbacks all migrations."`
+		} `positional-args:"yes" required:"yes"`
+	} `command:"to" description:"Migrate up or down to version N."`
+}
+
+func runMigrate(ctx context.Context, cmd MigrateCommand, action string, migrator *postgres.Migrator) error {
+	switch action {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		return migrator.Down(ctx)
+	case "to":
+		return migrator.To(ctx, cmd.To.Args.Version)
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "current version: %d\n", status.Current)
+		fmt.Fprintf(os.Stdout, "latest version: %d\n", status.Latest)
+		for _, name := range status.Pending {
+			fmt.Fprintf(os.Stdout, "pending: %s\n", name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command: %s", action)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/agalitsyn/go-app/internal/app/api"
+	"github.com/agalitsyn/go-app/internal/pkg/feature"
+	"github.com/agalitsyn/go-app/internal/pkg/flag"
+	"github.com/agalitsyn/go-app/internal/pkg/log"
+)
+
+// reloader re-reads config file and secret files, environment and command line can't change for running process.
+type reloader struct {
+	mu      sync.Mutex
+	cfg     CliFlags
+	logger  *log.StructuredLogger
+	runtime *api.Runtime
+}
+
+func newReloader(cfg CliFlags, logger *log.StructuredLogger, runtime *api.Runtime) *reloader {
+	return &reloader{cfg: cfg, logger: logger, runtime: runtime}
+}
+
+// Reload applies changed options tagged with reload. If any other option has changed, nothing is applied.
+func (r *reloader) Reload() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var cfg CliFlags
+	if _, err := flag.Parse(&cfg, os.Args[1:]); err != nil {
+		return nil, fmt.Errorf("could not parse config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var applied, rejected []string
+	for _, change := range flag.Diff(&r.cfg, &cfg) {
+		if change.Reloadable {
+			applied = append(applied, change.String())
+		} else {
+			rejected = append(rejected, change.String())
+		}
+	}
+	if len(rejected) > 0 {
+		r.logger.WithField("changes", rejected).Warn("config reload rejected")
+		return nil, fmt.Errorf("%w to apply %s", api.ErrRestartRequired, strings.Join(rejected, "; "))
+	}
+	if len(applied) == 0 {
+		r.logger.Info("config reloaded without changes")
+		return nil, nil
+	}
+
+	if err := applySettings(cfg, r.logger, r.runtime); err != nil {
+		return nil, err
+	}
+	r.cfg = cfg
+	r.logger.WithField("changes", applied).Info("config reloaded")
+	return applied, nil
+}
+
+func applySettings(cfg CliFlags, logger *log.StructuredLogger, runtime *api.Runtime) error {
+	if err := logger.Configure(cfg.Log.Format, cfg.Log.Level); err != nil {
+		return fmt.Errorf("could not configure logger: %w", err)
+	}
+	runtime.Apply(runtimeSettings(cfg))
+	feature.Set(cfg.Features)
+	return nil
+}
+
+func runtimeSettings(cfg CliFlags) api.Settings {
+	return api.Settings{
+		AllowedOrigins: cfg.HTTP.AllowedOrigins,
+		RateLimit:      cfg.HTTP.RateLimit,
+		RateBurst:      cfg.HTTP.RateBurst,
+	}
+}
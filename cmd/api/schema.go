@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/agalitsyn/go-app/internal/pkg/health"
+	"github.com/agalitsyn/go-app/internal/pkg/log"
+	"github.com/agalitsyn/go-app/internal/pkg/postgres"
+)
+
+// Policies for schema version mismatch, see CliFlags.Postgres.SchemaCheck.
+const (
+	schemaCheckFail     = "fail"
+	schemaCheckReadOnly = "readonly"
+	schemaCheckUnready  = "unready"
+	schemaCheckIgnore   = "ignore"
+)
+
+const schemaRecheckInterval = 10 * time.Second
+
+// schemaReadinessReason is reported while schema version mismatches with --schema-check=unready.
+const schemaReadinessReason = "schema"
+
+// checkSchemaVersion compares applied schema version with the version of embedded migrations
+// and applies the policy on mismatch. It returns true if the api must be started in read-only mode
+// and error if it must not be started.
+// Heartbeat is beaten while schema version is rechecked in background.
+func checkSchemaVersion(ctx context.Context, migrator *postgres.Migrator, policy string, bg *workers, heartbeat *health.Heartbeat, logger *log.StructuredLogger) (bool, error) {
+	if policy == schemaCheckIgnore {
+		return false, nil
+	}
+
+	applied, expected, err := schemaVersions(ctx, migrator)
+	if err != nil {
+		logger.WithError(err).Error("could not check schema version")
+	} else if applied == expected {
+		logger.Infof("schema version %d is up to date", applied)
+		return false, nil
+	} else {
+		logger.Errorf("schema version mismatch: applied %d, expected %d", applied, expected)
+	}
+
+	switch policy {
+	case schemaCheckReadOnly:
+		logger.Warn("starting in read-only mode because of schema version mismatch")
+		return true, nil
+	case schemaCheckUnready:
+		logger.Warn("readiness is unhealthy until schema version matches")
+		if err == nil {
+			err = fmt.Errorf("schema version mismatch: applied %d, expected %d", applied, expected)
+		}
+		// Own reason doesn't override readiness status set on shutdown.
+		health.SetUnready(schemaReadinessReason, err)
+		bg.Go(func(ctx context.Context) {
+			waitSchemaVersion(ctx, migrator, heartbeat, logger)
+		})
+		return false, nil
+	default:
+		return false, errors.New("refusing to start because of schema version mismatch, run `api migrate up` or use --schema-check to change the policy")
+	}
+}
+
+// waitSchemaVersion restores readiness as soon as migrations are applied, e.g. by another replica.
+func waitSchemaVersion(ctx context.Context, migrator *postgres.Migrator, heartbeat *health.Heartbeat, logger *log.StructuredLogger) {
+	heartbeat.Start()
+	defer heartbeat.Stop()
+	ticker := time.NewTicker(schemaRecheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			heartbeat.Beat()
+			applied, expected, err := schemaVersions(ctx, migrator)
+			if err != nil || applied != expected {
+				continue
+			}
+			logger.Infof("schema version %d is up to date, service is ready", applied)
+			health.SetUnready(schemaReadinessReason, nil)
+			return
+		}
+	}
+}
+
+func schemaVersions(ctx context.Context, migrator *postgres.Migrator) (applied, expected int32, err error) {
+	expected, err = migrator.ExpectedVersion()
+	if err != nil {
+		return 0, 0, err
+	}
+	applied, err = migrator.AppliedVersion(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	return applied, expected, nil
+}
+
+// schemaCheck is health check failing while applied schema version differs from embedded migrations.
+func schemaCheck(migrator *postgres.Migrator) health.CheckFunc {
+	return func(ctx context.Context) error {
+		applied, expected, err := schemaVersions(ctx, migrator)
+		if err != nil {
+			return err
+		}
+		if applied != expected {
+			return fmt.Errorf("schema version mismatch: applied %d, expected %d", applied, expected)
+		}
+		return nil
+	}
+}
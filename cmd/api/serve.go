@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/goware/cors"
+
+	"github.com/agalitsyn/go-app/internal/app/api"
+	"github.com/agalitsyn/go-app/internal/pkg/feature"
+	"github.com/agalitsyn/go-app/internal/pkg/health"
+	"github.com/agalitsyn/go-app/internal/pkg/lifecycle"
+	"github.com/agalitsyn/go-app/internal/pkg/log"
+	"github.com/agalitsyn/go-app/internal/pkg/postgres"
+	"github.com/agalitsyn/go-app/internal/storage"
+	"github.com/agalitsyn/go-app/internal/storage/sqlite"
+)
+
+type ServeCommand struct{}
+
+// runServe serves until SIGINT or SIGTERM, error is returned if start failed, server failed or shutdown timed out.
+func runServe(ctx context.Context, cfg CliFlags, pg *postgres.DB, migrator *postgres.Migrator, logger *log.StructuredLogger) error {
+	bg := newWorkers(ctx)
+	mgr := lifecycle.New(logger, cfg.StartupTimeout)
+	checks := health.NewRegistry()
+	stats := make(map[string]api.StatsFunc)
+
+	var (
+		articles storage.ArticleRepository
+		// readOnly is set on start, see checkSchemaVersion.
+		readOnly bool
+		poolWait func() time.Duration
+	)
+	sqliteDB := sqlite.New(cfg.SQLite.Path)
+	switch cfg.Storage {
+	case storagePostgres:
+		addPostgres(mgr, cfg, pg)
+	case storageSQLite:
+		addSQLite(mgr, sqliteDB, checks)
+	}
+	mgr.Add("workers", lifecycle.Hooks{OnStop: bg.Stop}, lifecycle.Options{})
+	switch cfg.Storage {
+	case storageMemory:
+		articles = newMemoryArticleStorage(logger)
+	case storageSQLite:
+		articles = sqlite.NewArticleStorage(sqliteDB)
+	default:
+		articles = addPostgresStorage(mgr, cfg, pg, migrator, bg, checks, stats, &readOnly, logger)
+		poolWait = pg.AcquireWait()
+	}
+
+	corsOptions := cors.Options{
+		AllowedOrigins:   cfg.HTTP.AllowedOrigins,
+		AllowedHeaders:   cfg.HTTP.AllowedHeaders,
+		ExposedHeaders:   cfg.HTTP.ExposedHeaders,
+		AllowedMethods:   []string{http.MethodGet, http.MethodPut, http.MethodDelete},
+		AllowCredentials: true,
+	}
+	runtime := api.NewRuntime(corsOptions, runtimeSettings(cfg))
+	feature.Set(cfg.Features)
+	reload := newReloader(cfg, logger, runtime)
+
+	var shedder *api.LoadShedder
+	if cfg.HTTP.MaxInFlight > 0 {
+		shedder = api.NewLoadShedder(api.SheddingOptions{
+			MaxInFlight: cfg.HTTP.MaxInFlight,
+			MinInFlight: cfg.HTTP.MinInFlight,
+			MaxPoolWait: cfg.HTTP.MaxPoolWait,
+			PoolWait:    poolWait,
+		})
+		stats["load_shedding"] = func() interface{} { return shedder.Stats() }
+	}
+
+	serverErr := make(chan error, 2)
+
+	// Servers have no dependencies on components which may start degraded, but they are registered last,
+	// so they are started after and stopped before the rest.
+	srv := &http.Server{
+		Addr:         cfg.HTTP.Addr,
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
+	}
+	mgr.Add("http", lifecycle.Hooks{
+		OnStart: func(ctx context.Context) error {
+			countMode := storage.CountExact
+			if cfg.HTTP.ListCount == "estimate" {
+				countMode = storage.CountEstimate
+			}
+			apiCfg := api.Config{
+				CORSOptions:    corsOptions,
+				DocsPath:       cfg.DocsPath,
+				ReadOnly:       readOnly,
+				Runtime:        runtime,
+				Health:         checks,
+				RequestTimeout: cfg.HTTP.RequestTimeout,
+				LoadShedder:    shedder,
+			}
+			if cfg.Postgres.ReadYourWrites {
+				apiCfg.Middlewares = append(apiCfg.Middlewares, postgres.TrackWritesHandler)
+			}
+			srv.Handler = api.New(apiCfg, logger, api.NewArticleService(articles, countMode))
+			return listenAndServe(srv, serverErr, logger)
+		},
+		OnStop: srv.Shutdown,
+	}, lifecycle.Options{})
+
+	if cfg.HTTP.AdminAddr != "" {
+		adminSrv := &http.Server{Addr: cfg.HTTP.AdminAddr, Handler: api.NewAdmin(logger, reload, stats)}
+		mgr.Add("admin", lifecycle.Hooks{
+			OnStart: func(ctx context.Context) error {
+				return listenAndServe(adminSrv, serverErr, logger)
+			},
+			OnStop: adminSrv.Shutdown,
+		}, lifecycle.Options{})
+	}
+
+	var started int32
+	checks.Register("startup", func(ctx context.Context) error {
+		if atomic.LoadInt32(&started) == 0 {
+			return errors.New("components are starting")
+		}
+		return nil
+	}, health.CheckOptions{Probes: health.Startup})
+	checks.Register("shutdown", health.ReadinessCheck, health.CheckOptions{Probes: health.Readiness})
+
+	sigquit := make(chan os.Signal, 1)
+	signal.Ignore(syscall.SIGPIPE)
+	signal.Notify(sigquit, syscall.SIGINT, syscall.SIGTERM)
+
+	// Signal during start cancels it, e.g. waiting for database, so the process exits promptly.
+	startCtx, stopStart := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	err := mgr.Start(startCtx)
+	interrupted := startCtx.Err() != nil
+	stopStart()
+	if err == nil && interrupted {
+		err = errors.New("start interrupted by signal")
+		mgr.Stop(ctx)
+	}
+	if err != nil {
+		pg.Close()
+		return err
+	}
+	atomic.StoreInt32(&started, 1)
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	bg.Go(func(ctx context.Context) {
+		defer signal.Stop(sighup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sighup:
+				logger.Info("captured SIGHUP, reloading config")
+				if _, err := reload.Reload(); err != nil {
+					logger.WithError(err).Error("could not reload config")
+				}
+			}
+		}
+	})
+
+	select {
+	case s := <-sigquit:
+		logger.Infof("captured %v, exiting...", s)
+
+		// Load balancer needs some time to notice that the service is unready, requests are served meanwhile.
+		health.SetReadinessStatus(http.StatusServiceUnavailable)
+		if cfg.HTTP.ShutdownDelay > 0 {
+			logger.Infof("waiting %s before shutdown", cfg.HTTP.ShutdownDelay)
+			time.Sleep(cfg.HTTP.ShutdownDelay)
+		}
+	case err = <-serverErr:
+		logger.WithError(err).Error("server error")
+		health.SetReadinessStatus(http.StatusServiceUnavailable)
+	}
+
+	// Servers stop accepting requests and wait for active ones, then background workers are stopped
+	// and database connections are closed, so requests and workers never see closed pool.
+	logger.Info("gracefully shutdown")
+	shutdownCtx, cancel := context.WithTimeout(ctx, cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+	stopErr := mgr.Stop(shutdownCtx)
+	// Pool is created even if degraded postgres component failed, which is not stopped by manager.
+	pg.Close()
+	if stopErr != nil {
+		return stopErr
+	}
+
+	logger.Info("shutdown complete")
+	return err
+}
+
+// listenAndServe binds address synchronously, so the error is returned on start, and serves in background.
+func listenAndServe(srv *http.Server, serverErr chan<- error, logger *log.StructuredLogger) error {
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("listening on %s", srv.Addr)
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			serverErr <- fmt.Errorf("%s: %w", srv.Addr, err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/agalitsyn/go-app/internal/app/api"
+	"github.com/agalitsyn/go-app/internal/pkg/breaker"
+	"github.com/agalitsyn/go-app/internal/pkg/health"
+	"github.com/agalitsyn/go-app/internal/pkg/lifecycle"
+	"github.com/agalitsyn/go-app/internal/pkg/log"
+	"github.com/agalitsyn/go-app/internal/pkg/postgres"
+	"github.com/agalitsyn/go-app/internal/storage"
+	"github.com/agalitsyn/go-app/internal/storage/cache"
+	"github.com/agalitsyn/go-app/internal/storage/guard"
+	"github.com/agalitsyn/go-app/internal/storage/memory"
+	"github.com/agalitsyn/go-app/internal/storage/rdb"
+	"github.com/agalitsyn/go-app/internal/storage/sqlite"
+)
+
+// Values of --storage.
+const (
+	storagePostgres = "postgres"
+	storageMemory   = "memory"
+	storageSQLite   = "sqlite"
+)
+
+func postgresPolicy(cfg CliFlags) lifecycle.Policy {
+	if cfg.Postgres.Startup == "degraded" {
+		return lifecycle.Degraded
+	}
+	return lifecycle.FailFast
+}
+
+// addPostgres adds database pool component, it must be registered before workers,
+// so they are stopped before the pool is closed.
+func addPostgres(mgr *lifecycle.Manager, cfg CliFlags, pg *postgres.DB) {
+	policy := postgresPolicy(cfg)
+	mgr.Add("postgres", lifecycle.Hooks{
+		OnStart: func(ctx context.Context) error {
+			if policy == lifecycle.FailFast {
+				return pg.Connect(ctx)
+			}
+			// Pool connects on demand, so requests which need database fail until it's available
+			// and succeed after that without restart.
+			if err := pg.ConnectLazy(); err != nil {
+				return err
+			}
+			return pg.Session.Ping(ctx)
+		},
+		OnStop: func(ctx context.Context) error {
+			pg.Close()
+			return nil
+		},
+	}, lifecycle.Options{Policy: policy})
+}
+
+// addPostgresStorage adds components which watch database and returns article storage decorated
+// with circuit breaker and cache if they are enabled. readOnly is set on start.
+func addPostgresStorage(
+	mgr *lifecycle.Manager,
+	cfg CliFlags,
+	pg *postgres.DB,
+	migrator *postgres.Migrator,
+	bg *workers,
+	checks *health.Registry,
+	stats map[string]api.StatsFunc,
+	readOnly *bool,
+	logger *log.StructuredLogger,
+) storage.ArticleRepository {
+	// Monitor keeps pinging database after start, so the service reports unready while it's down
+	// and recovers without restart. It doesn't depend on postgres, which may fail to start in degraded mode.
+	monitor := postgres.NewMonitor(pg, postgres.MonitorOptions{
+		Interval:         cfg.Postgres.MonitorInterval,
+		FailureThreshold: cfg.Postgres.MonitorThreshold,
+	})
+	mgr.Add("postgres-monitor", lifecycle.Hooks{
+		OnStart: func(ctx context.Context) error {
+			bg.Go(monitor.Run)
+			return nil
+		},
+	}, lifecycle.Options{DependsOn: []string{"workers"}})
+	checks.Register("postgres", monitor.Check, health.CheckOptions{Probes: health.Readiness})
+	checks.Register("postgres-monitor", monitor.Heartbeat().Check, health.CheckOptions{Probes: health.Liveness})
+
+	schemaWatcher := health.NewHeartbeat(schemaRecheckInterval)
+	checks.Register("schema-watcher", schemaWatcher.Check, health.CheckOptions{Probes: health.Liveness})
+	if cfg.Postgres.SchemaCheck == schemaCheckUnready {
+		checks.Register("schema", schemaCheck(migrator), health.CheckOptions{Probes: health.Readiness, CacheTTL: schemaRecheckInterval})
+	}
+	mgr.Add("schema", lifecycle.Hooks{
+		OnStart: func(ctx context.Context) error {
+			if cfg.Postgres.MigrateOnStart {
+				if err := migrator.Up(ctx); err != nil {
+					return fmt.Errorf("could not migrate on start: %w", err)
+				}
+			}
+			var err error
+			*readOnly, err = checkSchemaVersion(ctx, migrator, cfg.Postgres.SchemaCheck, bg, schemaWatcher, logger)
+			return err
+		},
+	}, lifecycle.Options{DependsOn: []string{"postgres", "workers"}, Policy: postgresPolicy(cfg)})
+
+	stats["postgres"] = func() interface{} { return pg.Stats() }
+
+	var articles storage.ArticleRepository = rdb.NewArticleStorage(pg)
+	if cfg.Postgres.BreakerFailureRatio > 0 {
+		b := breaker.New(breaker.Options{
+			FailureRatio:     cfg.Postgres.BreakerFailureRatio,
+			MinRequests:      cfg.Postgres.BreakerMinRequests,
+			Window:           cfg.Postgres.BreakerWindow,
+			OpenTimeout:      cfg.Postgres.BreakerOpenTimeout,
+			HalfOpenRequests: cfg.Postgres.BreakerProbes,
+			IsFailure:        guard.IsFailure,
+		})
+		articles = guard.NewArticleStorage(articles, b)
+		checks.Register("postgres-breaker", b.Check, health.CheckOptions{Probes: health.Readiness})
+		stats["postgres_breaker"] = func() interface{} { return b.Stats() }
+	}
+
+	if cfg.Cache.Size > 0 {
+		cached := cache.NewArticleStorage(articles, cache.Options{Size: cfg.Cache.Size, TTL: cfg.Cache.TTL})
+		articles = cached
+		// Changes made by other instances and commands are reported by database triggers.
+		mgr.Add("article-cache", lifecycle.Hooks{
+			OnStart: func(ctx context.Context) error {
+				bg.Go(func(ctx context.Context) {
+					pg.Listen(ctx, rdb.ArticleChangedChannel, func(string) { cached.Invalidate() })
+				})
+				return nil
+			},
+		}, lifecycle.Options{DependsOn: []string{"workers"}})
+		stats["article_cache"] = func() interface{} { return cached.Stats() }
+	}
+	return articles
+}
+
+// newMemoryArticleStorage returns storage with demo articles, since it's empty on every start.
+func newMemoryArticleStorage(logger *log.StructuredLogger) storage.ArticleRepository {
+	articles := memory.NewArticleStorage()
+	// Memory storage never fails.
+	_ = articles.StoreArticles(context.Background(), seedArticles())
+	logger.Warn("articles are stored in memory and will be lost on exit")
+	return articles
+}
+
+// addSQLite adds database file component, it must be registered before workers like addPostgres.
+func addSQLite(mgr *lifecycle.Manager, db *sqlite.DB, checks *health.Registry) {
+	mgr.Add("sqlite", lifecycle.Hooks{
+		OnStart: db.Open,
+		OnStop: func(ctx context.Context) error {
+			return db.Close()
+		},
+	}, lifecycle.Options{})
+	checks.Register("sqlite", db.Ping, health.CheckOptions{Probes: health.Readiness})
+}
+
+// runSQLiteCommand runs commands which support SQLite storage and exit when done.
+func runSQLiteCommand(ctx context.Context, cfg CliFlags, command []string) error {
+	switch command[0] {
+	case "seed", "export", "import":
+	default:
+		return fmt.Errorf("supported only with --storage=%s", storagePostgres)
+	}
+
+	db := sqlite.New(cfg.SQLite.Path)
+	if err := db.Open(ctx); err != nil {
+		return err
+	}
+	defer db.Close()
+	return runArticleCommand(ctx, cfg, command, sqlite.NewArticleStorage(db))
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/agalitsyn/go-app/internal/storage"
+)
+
+const apiKeyBytes = 32
+
+type UserCommand struct {
+	Create struct {
+		Email    string `long:"email" required:"yes" description:"User email."`
+		Password string `long:"password" description:"User password, read from stdin if empty."`
+	} `command:"create" description:"Create a user."`
+}
+
+type APIKeyCommand struct {
+	Create struct {
+		Email string `long:"email" required:"yes" description:"Email of the key owner."`
+		Name  string `long:"name" required:"yes" description:"Name of the key, e.g. where it is used."`
+	} `command:"create" description:"Create an API key, the key is printed only once."`
+}
+
+func runUserCreate(ctx context.Context, cmd UserCommand, store storage.UserRepository) error {
+	password := cmd.Create.Password
+	if password == "" {
+		var err error
+		if password, err = readPassword(); err != nil {
+			return err
+		}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("could not hash password: %w", err)
+	}
+
+	user, err := store.CreateUser(ctx, storage.User{Email: cmd.Create.Email, PasswordHash: string(hash)})
+	if err != nil {
+		return fmt.Errorf("could not create user: %w", err)
+	}
+	fmt.Fprintf(os.Stdout, "created user %d: %s\n", user.ID, user.Email)
+	return nil
+}
+
+func runAPIKeyCreate(ctx context.Context, cmd APIKeyCommand, store storage.UserRepository) error {
+	user, err := store.GetUserByEmail(ctx, cmd.Create.Email)
+	if err != nil {
+		return fmt.Errorf("could not get user: %w", err)
+	}
+
+	buf := make([]byte, apiKeyBytes)
+	if _, err = rand.Read(buf); err != nil {
+		return fmt.Errorf("could not generate key: %w", err)
+	}
+	key := hex.EncodeToString(buf)
+	hash := sha256.Sum256([]byte(key))
+
+	_, err = store.CreateAPIKey(ctx, storage.APIKey{
+		UserID:  user.ID,
+		Name:    cmd.Create.Name,
+		KeyHash: hex.EncodeToString(hash[:]),
+	})
+	if err != nil {
+		return fmt.Errorf("could not create key: %w", err)
+	}
+	fmt.Fprintln(os.Stdout, key)
+	return nil
+}
+
+// readPassword reads the first line of stdin, so password doesn't end up in shell history.
+func readPassword() (string, error) {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("could not read password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("empty password")
+	}
+	return password, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// workers runs background goroutines, which are canceled and awaited on shutdown.
+type workers struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newWorkers(ctx context.Context) *workers {
+	ctx, cancel := context.WithCancel(ctx)
+	return &workers{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn in background, fn must return when ctx is done.
+func (w *workers) Go(fn func(ctx context.Context)) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		fn(w.ctx)
+	}()
+}
+
+// Stop cancels workers and waits until they return or ctx is done.
+func (w *workers) Stop(ctx context.Context) error {
+	w.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
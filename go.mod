@@ -1,16 +1,24 @@
 module github.com/agalitsyn/go-app
 
-go 1.12
+go 1.16
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/Masterminds/squirrel v1.5.0
 	github.com/go-chi/chi v3.3.2+incompatible
 	github.com/go-chi/render v1.0.0
 	github.com/golangci/golangci-lint v1.39.0
 	github.com/goware/cors v1.0.0
+	github.com/jackc/pgconn v1.8.1
 	github.com/jackc/pgx/v4 v4.11.0
 	github.com/jackc/tern v1.12.4
 	github.com/jessevdk/go-flags v1.3.0
 	github.com/sirupsen/logrus v1.8.1
 	github.com/stretchr/testify v1.7.0
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2
+	golang.org/x/sync v0.0.0-20201020160332-67f06af15bc9
+	golang.org/x/text v0.3.5
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
+	modernc.org/sqlite v1.14.2
 )
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+
+	"github.com/agalitsyn/go-app/internal/pkg/log"
+	mw "github.com/agalitsyn/go-app/internal/pkg/middleware"
+	"github.com/agalitsyn/go-app/internal/pkg/response"
+)
+
+// ErrRestartRequired is returned by Reloader if configuration has changes which can't be applied at runtime.
+var ErrRestartRequired = errors.New("restart required")
+
+// Reloader re-reads configuration and applies settings which can be changed at runtime.
+type Reloader interface {
+	// Reload returns descriptions of applied changes.
+	Reload() ([]string, error)
+}
+
+// StatsFunc returns a snapshot of a subsystem statistics, it's rendered as JSON.
+type StatsFunc func() interface{}
+
+// NewAdmin creates router for administrative endpoints, it must be served on a private address.
+func NewAdmin(logger *log.StructuredLogger, reloader Reloader, stats map[string]StatsFunc) chi.Router {
+	r := chi.NewRouter()
+	r.Use(
+		middleware.RequestID,
+		mw.RequestLogger(logger),
+		middleware.Recoverer,
+	)
+
+	r.Post("/reload", func(w http.ResponseWriter, r *http.Request) {
+		changes, err := reloader.Reload()
+		if errors.Is(err, ErrRestartRequired) {
+			response.MustRender(w, r, response.ErrConflict(err))
+			return
+		}
+		if err != nil {
+			response.MustRender(w, r, response.ErrBadRequest(err))
+			return
+		}
+
+		if changes == nil {
+			changes = []string{}
+		}
+		render.JSON(w, r, reloadResponse{Changes: changes})
+	})
+
+	r.Get("/stats", func(w http.ResponseWriter, r *http.Request) {
+		res := make(map[string]interface{}, len(stats))
+		for name, fn := range stats {
+			res[name] = fn()
+		}
+		render.JSON(w, r, res)
+	})
+
+	return r
+}
+
+type reloadResponse struct {
+	Changes []string `json:"changes"`
+}
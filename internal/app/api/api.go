@@ -1,7 +1,9 @@
 package api
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
@@ -16,21 +18,72 @@ import (
 type Config struct {
 	CORSOptions cors.Options
 	DocsPath    string
+	// ReadOnly rejects all requests which can modify data.
+	ReadOnly bool
+	// Runtime allows changing settings of running server, if nil settings are taken from CORSOptions.
+	Runtime *Runtime
+	// Health checks are served on probe endpoints, nil means no checks.
+	Health *health.Registry
+	// Middlewares are applied to all requests after the common ones.
+	Middlewares []func(http.Handler) http.Handler
+	// RequestTimeout is deadline of API requests, zero means no deadline.
+	RequestTimeout time.Duration
+	// LoadShedder limits concurrent API requests, nil means no limit.
+	LoadShedder *LoadShedder
 }
 
 func New(cfg Config, logger *log.StructuredLogger, articleService *ArticleService) chi.Router {
+	rt := cfg.Runtime
+	if rt == nil {
+		rt = NewRuntime(cfg.CORSOptions, Settings{AllowedOrigins: cfg.CORSOptions.AllowedOrigins})
+	}
+
 	r := chi.NewRouter()
 	r.Use( // note: order of middlewares is important
 		middleware.RequestID,
 		middleware.RealIP,
 		mw.RequestLogger(logger),
 		middleware.Recoverer,
-		cors.New(cfg.CORSOptions).Handler,
+		rt.corsHandler,
 	)
+	r.Use(cfg.Middlewares...)
+	if cfg.ReadOnly {
+		r.Use(readOnly)
+	}
 
 	r.Mount("/readiness", health.Routes())
+	healthChecks := cfg.Health
+	if healthChecks == nil {
+		healthChecks = health.NewRegistry()
+	}
+	healthChecks.RegisterRoutes(r)
+
 	r.Route("/1.0", func(r chi.Router) {
-		r.Use(mw.APIVersion("1.0"))
+		r.Use(
+			rt.limiter.Handler,
+			mw.APIVersion("1.0"),
+		)
+		if cfg.LoadShedder != nil {
+			r.Use(cfg.LoadShedder.Handler)
+		}
+		if cfg.RequestTimeout > 0 {
+			r.Use(withTimeout(cfg.RequestTimeout))
+		}
+
+		r.Mount("/articles", articleService.Routes())
+	})
+	r.Route("/1.1", func(r chi.Router) {
+		r.Use(
+			rt.limiter.Handler,
+			mw.APIVersion("1.1"),
+			mw.ListEnvelope(),
+		)
+		if cfg.LoadShedder != nil {
+			r.Use(cfg.LoadShedder.Handler)
+		}
+		if cfg.RequestTimeout > 0 {
+			r.Use(withTimeout(cfg.RequestTimeout))
+		}
 
 		r.Mount("/articles", articleService.Routes())
 	})
@@ -39,3 +92,14 @@ func New(cfg Config, logger *log.StructuredLogger, articleService *ArticleServic
 
 	return r
 }
+
+func readOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+		default:
+			response.MustRender(w, r, response.ErrServiceUnavailable(errors.New("service is in read-only mode")))
+		}
+	})
+}
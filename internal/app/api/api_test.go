@@ -0,0 +1,40 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/agalitsyn/go-app/internal/pkg/log"
+	"github.com/agalitsyn/go-app/internal/storage"
+)
+
+func TestReadOnly(t *testing.T) {
+	t.Parallel()
+
+	service := NewArticleService(newTestArticleStorage(t, storage.Article{Title: "Foo", Slug: "foo"}), storage.CountExact)
+	r := New(Config{ReadOnly: true}, log.New("text", "error", ioutil.Discard), service)
+
+	tests := []struct {
+		method string
+		code   int
+	}{
+		{method: http.MethodGet, code: http.StatusOK},
+		{method: http.MethodPut, code: http.StatusServiceUnavailable},
+		{method: http.MethodDelete, code: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.method, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, "/1.0/articles/foo", nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Result().StatusCode)
+		})
+	}
+}
@@ -1,12 +1,22 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
 
+	"github.com/agalitsyn/go-app/internal/pkg/breaker"
+	"github.com/agalitsyn/go-app/internal/pkg/filter"
 	"github.com/agalitsyn/go-app/internal/pkg/log"
 	"github.com/agalitsyn/go-app/internal/pkg/response"
+	"github.com/agalitsyn/go-app/internal/pkg/slug"
 	"github.com/agalitsyn/go-app/internal/storage"
 
 	"github.com/go-chi/chi"
@@ -14,12 +24,14 @@ import (
 )
 
 type ArticleService struct {
-	store storage.ArticleRepository
+	store     storage.ArticleRepository
+	countMode storage.CountMode
 }
 
-func NewArticleService(store storage.ArticleRepository) *ArticleService {
+func NewArticleService(store storage.ArticleRepository, countMode storage.CountMode) *ArticleService {
 	return &ArticleService{
-		store: store,
+		store:     store,
+		countMode: countMode,
 	}
 }
 
@@ -29,6 +41,8 @@ func (s *ArticleService) Routes() chi.Router {
 	r.Get("/", s.listHandler)
 	r.Post("/", s.storeHandler)
 	r.Route("/{slug}", func(r chi.Router) {
+		r.Get("/", s.getHandler)
+		r.Put("/", s.updateHandler)
 		r.Delete("/", s.deleteHandler)
 	})
 
@@ -39,14 +53,83 @@ func (s *ArticleService) listHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	logger := log.RequestLogger(r)
 
-	articles, err := s.store.FilterArticles(ctx, storage.ArticleFilter{})
+	filter, err := parseArticleFilter(r)
+	if err != nil {
+		response.MustRender(w, r, response.ErrBadRequest(err))
+		return
+	}
+
+	articles, err := s.store.FilterArticles(ctx, filter)
 	if err != nil {
 		logger.WithError(err).Error("could not filter articles")
-		response.MustRender(w, r, response.ErrUnknown(err))
+		renderStorageError(w, r, err)
 		return
 	}
 
-	response.MustRenderList(w, r, newArticleListResponse(articles))
+	page := response.Page{Limit: filter.Limit, Offset: filter.Offset, Item: &articleResponse{}}
+	if response.ListEnvelopeEnabled(r) {
+		total, err := s.store.CountArticles(ctx, filter, s.countMode)
+		if err != nil {
+			logger.WithError(err).Error("could not count articles")
+			renderStorageError(w, r, err)
+			return
+		}
+		page.Total = &total
+	}
+
+	response.MustRenderPage(w, r, newArticleListResponse(articles), page)
+}
+
+func parseArticleFilter(r *http.Request) (storage.ArticleFilter, error) {
+	var params storage.ArticleFilter
+	q := r.URL.Query()
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return params, fmt.Errorf("invalid limit: %w", err)
+		}
+		params.Limit = limit
+	}
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return params, fmt.Errorf("invalid offset: %w", err)
+		}
+		params.Offset = offset
+	}
+
+	if v := q.Get("fields"); v != "" {
+		for _, field := range strings.Split(v, ",") {
+			switch field = strings.TrimSpace(field); field {
+			case storage.ArticleFieldID, storage.ArticleFieldTitle, storage.ArticleFieldSlug:
+				params.Fields = append(params.Fields, field)
+			default:
+				return params, fmt.Errorf("unknown field: %s", field)
+			}
+		}
+	}
+	if v := q.Get("filter"); v != "" {
+		expr, err := filter.ParseWithSchema(v, storage.ArticleFilterSchema)
+		if err != nil {
+			return params, err
+		}
+		params.Expr = expr
+	}
+	if v := q.Get("include"); v != "" {
+		for _, include := range strings.Split(v, ",") {
+			switch include = strings.TrimSpace(include); include {
+			case "author":
+				params.WithAuthor = true
+			case "tags":
+				params.WithTags = true
+			default:
+				return params, fmt.Errorf("unknown include: %s", include)
+			}
+		}
+	}
+
+	return params, nil
 }
 
 func (s *ArticleService) storeHandler(w http.ResponseWriter, r *http.Request) {
@@ -69,9 +152,117 @@ func (s *ArticleService) storeHandler(w http.ResponseWriter, r *http.Request) {
 		Slug:  data.Slug,
 	}
 
-	if err := s.store.StoreArticles(ctx, []storage.Article{article}); err != nil {
-		logger.WithError(err).Error("could not store articles")
-		response.MustRender(w, r, response.ErrUnknown(err))
+	err := s.createArticle(ctx, &article)
+	if errors.Is(err, storage.ErrArticleConflict) {
+		response.MustRender(w, r, response.ErrConflict(fmt.Errorf("slug %q is already used", article.Slug)))
+		return
+	}
+	if errors.Is(err, errEmptySlug) {
+		response.MustRender(w, r, response.ErrBadRequest(err))
+		return
+	}
+	if err != nil {
+		logger.WithError(err).Error("could not create article")
+		renderStorageError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", path.Join(r.URL.Path, strings.ToLower(article.Slug)))
+	render.Status(r, http.StatusOK)
+}
+
+// createSlugAttempts limits retries of generated slugs taken by concurrent requests.
+const createSlugAttempts = 3
+
+var errEmptySlug = errors.New("could not generate slug from title")
+
+// createArticle stores article with given slug, updating existing one, or creates a new one with slug
+// generated from title. Generated slug may be taken by a concurrent request before insert,
+// then the next free one is tried instead of overwriting that article.
+func (s *ArticleService) createArticle(ctx context.Context, article *storage.Article) error {
+	if article.Slug != "" {
+		return s.store.StoreArticles(ctx, []storage.Article{*article})
+	}
+
+	base := slug.Make(article.Title)
+	if base == "" {
+		return errEmptySlug
+	}
+	var err error
+	for attempt := 0; attempt < createSlugAttempts; attempt++ {
+		article.Slug, err = s.store.UniqueArticleSlug(ctx, base)
+		if err != nil {
+			return fmt.Errorf("could not generate article slug: %w", err)
+		}
+		err = s.store.CreateArticle(ctx, *article)
+		if !errors.Is(err, storage.ErrArticleConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *ArticleService) getHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := log.RequestLogger(r)
+	articleSlug := chi.URLParam(r, "slug")
+
+	article, err := s.store.GetArticle(ctx, articleSlug)
+	if errors.Is(err, storage.ErrArticleNotFound) {
+		response.MustRender(w, r, response.ErrNotFound(err))
+		return
+	}
+	if err != nil {
+		logger.WithError(err).Error("could not get article")
+		renderStorageError(w, r, err)
+		return
+	}
+
+	// Article was found by one of previous slugs, redirect client to the canonical one.
+	if !strings.EqualFold(article.Slug, articleSlug) {
+		location := path.Join(path.Dir(strings.TrimSuffix(r.URL.Path, "/")), article.Slug)
+		if r.URL.RawQuery != "" {
+			location += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, location, http.StatusMovedPermanently)
+		return
+	}
+
+	response.MustRender(w, r, newArticleResponse(article))
+}
+
+func (s *ArticleService) updateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := log.RequestLogger(r)
+	articleSlug := chi.URLParam(r, "slug")
+
+	var data articleRequest
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.MustRender(w, r, response.ErrBadRequest(err))
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		response.MustRender(w, r, response.ErrBadRequest(err))
+		return
+	}
+
+	article := storage.Article{
+		Title: data.Title,
+		Slug:  data.Slug,
+	}
+
+	err := s.store.UpdateArticle(ctx, articleSlug, article)
+	switch {
+	case errors.Is(err, storage.ErrArticleNotFound):
+		response.MustRender(w, r, response.ErrNotFound(err))
+		return
+	case errors.Is(err, storage.ErrArticleConflict):
+		response.MustRender(w, r, response.ErrConflict(fmt.Errorf("slug %q is already used", data.Slug)))
+		return
+	case err != nil:
+		logger.WithError(err).Error("could not update article")
+		renderStorageError(w, r, err)
 		return
 	}
 
@@ -81,17 +272,28 @@ func (s *ArticleService) storeHandler(w http.ResponseWriter, r *http.Request) {
 func (s *ArticleService) deleteHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	logger := log.RequestLogger(r)
-	slug := chi.URLParam(r, "slug")
+	articleSlug := chi.URLParam(r, "slug")
 
-	if err := s.store.DeleteArticles(ctx, []storage.Article{{Slug: slug}}); err != nil {
+	if err := s.store.DeleteArticles(ctx, []storage.Article{{Slug: articleSlug}}); err != nil {
 		logger.WithError(err).Error("could not delete articles")
-		response.MustRender(w, r, response.ErrUnknown(err))
+		renderStorageError(w, r, err)
 		return
 	}
 
 	render.NoContent(w, r)
 }
 
+// renderStorageError reports rejected calls of storage with 503, so clients retry later, and other errors with 500.
+func renderStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	var openErr *breaker.OpenError
+	if errors.As(err, &openErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(openErr.RetryAfter.Seconds()))))
+		response.MustRender(w, r, response.ErrServiceUnavailable(err))
+		return
+	}
+	response.MustRender(w, r, response.ErrUnknown(err))
+}
+
 func newArticleListResponse(articles []storage.Article) []render.Renderer {
 	var list []render.Renderer
 	for _, article := range articles {
@@ -101,17 +303,37 @@ func newArticleListResponse(articles []storage.Article) []render.Renderer {
 }
 
 func newArticleResponse(article storage.Article) *articleResponse {
-	return &articleResponse{
+	resp := &articleResponse{
 		ID:    article.ID,
 		Title: article.Title,
 		Slug:  article.Slug,
+		Tags:  article.Tags,
+	}
+	if article.Author != nil {
+		resp.Author = &authorResponse{
+			ID:   article.Author.ID,
+			Name: article.Author.Name,
+		}
 	}
+	return resp
 }
 
+// articleResponse omits empty fields, so sparse fieldsets and includes
+// are controlled by what was loaded from the storage.
 type articleResponse struct {
-	ID    int    `json:"id"`
-	Title string `json:"title"`
-	Slug  string `json:"slug"`
+	XMLName xml.Name `json:"-" xml:"article"`
+
+	ID    int    `json:"id" xml:"id"`
+	Title string `json:"title,omitempty" xml:"title,omitempty"`
+	Slug  string `json:"slug,omitempty" xml:"slug,omitempty"`
+
+	Author *authorResponse `json:"author,omitempty" xml:"author,omitempty"`
+	Tags   []string        `json:"tags,omitempty" xml:"tags>tag,omitempty"`
+}
+
+type authorResponse struct {
+	ID   int    `json:"id" xml:"id"`
+	Name string `json:"name" xml:"name"`
 }
 
 func (*articleResponse) Render(w http.ResponseWriter, r *http.Request) error {
@@ -123,12 +345,10 @@ type articleRequest struct {
 	Slug  string `json:"slug"`
 }
 
+// Validate checks request, slug is optional and generated from title if empty.
 func (r *articleRequest) Validate() error {
 	if r.Title == "" {
 		return errors.New("title is empty")
 	}
-	if r.Slug == "" {
-		return errors.New("slug is empty")
-	}
 	return nil
 }
@@ -3,15 +3,19 @@ package api
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	mw "github.com/agalitsyn/go-app/internal/pkg/middleware"
 	"github.com/agalitsyn/go-app/internal/storage"
+	"github.com/agalitsyn/go-app/internal/storage/memory"
 
 	"github.com/go-chi/chi"
 )
@@ -19,10 +23,8 @@ import (
 func TestArticleService_listHandler(t *testing.T) {
 	t.Parallel()
 
-	store := newMockArticleStorage(map[int]storage.Article{
-		1: {ID: 1, Title: "Foo", Slug: "foo"},
-	})
-	service := NewArticleService(store)
+	store := newTestArticleStorage(t, storage.Article{Title: "Foo", Slug: "foo"})
+	service := NewArticleService(store, storage.CountExact)
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -39,10 +41,196 @@ func TestArticleService_listHandler(t *testing.T) {
 	assert.NotEmpty(t, body)
 }
 
+// authorArticleStorage embeds the same author into articles if requested, memory storage can't store authors.
+type authorArticleStorage struct {
+	*memory.ArticleStorage
+}
+
+func (s authorArticleStorage) FilterArticles(ctx context.Context, params storage.ArticleFilter) ([]storage.Article, error) {
+	articles, err := s.ArticleStorage.FilterArticles(ctx, params)
+	if params.WithAuthor {
+		for i := range articles {
+			articles[i].Author = &storage.Author{ID: 1, Name: "Rob"}
+		}
+	}
+	return articles, err
+}
+
+func TestArticleService_listHandler_sparse(t *testing.T) {
+	t.Parallel()
+
+	store := newTestArticleStorage(t, storage.Article{Title: "Go", Slug: "go", Tags: []string{"go", "books"}})
+	service := NewArticleService(authorArticleStorage{store}, storage.CountExact)
+
+	r := chi.NewRouter()
+	r.Get("/", service.listHandler)
+
+	tests := []struct {
+		name  string
+		query string
+		code  int
+		// body is expected JSON of successful response.
+		body string
+	}{
+		{
+			name:  "all fields",
+			query: "",
+			code:  http.StatusOK,
+			body:  `[{"id": 1, "title": "Go", "slug": "go"}]`,
+		},
+		{
+			name:  "fields",
+			query: "?fields=title",
+			code:  http.StatusOK,
+			body:  `[{"id": 1, "title": "Go"}]`,
+		},
+		{
+			name:  "unknown field",
+			query: "?fields=title,password",
+			code:  http.StatusBadRequest,
+		},
+		{
+			name:  "include",
+			query: "?include=author,tags",
+			code:  http.StatusOK,
+			body:  `[{"id": 1, "title": "Go", "slug": "go", "author": {"id": 1, "name": "Rob"}, "tags": ["books", "go"]}]`,
+		},
+		{
+			name:  "fields and include",
+			query: "?fields=slug&include=tags",
+			code:  http.StatusOK,
+			body:  `[{"id": 1, "slug": "go", "tags": ["books", "go"]}]`,
+		},
+		{
+			name:  "unknown include",
+			query: "?include=comments",
+			code:  http.StatusBadRequest,
+		},
+		{
+			name:  "filter",
+			query: "?filter=" + url.QueryEscape(`title~"GO" and id>0`),
+			code:  http.StatusOK,
+			body:  `[{"id": 1, "title": "Go", "slug": "go"}]`,
+		},
+		{
+			name:  "invalid filter",
+			query: "?filter=" + url.QueryEscape(`title~`),
+			code:  http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
+
+			r.ServeHTTP(w, req)
+
+			resp := w.Result()
+			assert.Equal(t, tt.code, resp.StatusCode)
+			if tt.body != "" {
+				body, err := ioutil.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, tt.body, string(body))
+			}
+		})
+	}
+}
+
+func TestArticleService_listHandler_envelope(t *testing.T) {
+	t.Parallel()
+
+	store := newTestArticleStorage(t,
+		storage.Article{Title: "Foo", Slug: "foo"},
+		storage.Article{Title: "Bar", Slug: "bar"},
+		storage.Article{Title: "Baz", Slug: "baz"},
+	)
+	service := NewArticleService(store, storage.CountExact)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/?limit=1&offset=1", nil)
+
+	r := chi.NewRouter()
+	r.Use(mw.ListEnvelope())
+	r.Get("/", service.listHandler)
+	r.ServeHTTP(w, req)
+
+	resp := w.Result()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Data []articleResponse `json:"data"`
+		Meta struct {
+			Total int64  `json:"total"`
+			Next  uint64 `json:"next"`
+			Prev  uint64 `json:"prev"`
+		} `json:"meta"`
+		Links struct {
+			Next string `json:"next"`
+		} `json:"links"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	require.Len(t, body.Data, 1)
+	assert.Equal(t, "baz", body.Data[0].Slug)
+	assert.Equal(t, int64(3), body.Meta.Total)
+	assert.Equal(t, uint64(2), body.Meta.Next)
+	assert.Equal(t, uint64(0), body.Meta.Prev)
+	assert.Equal(t, "/?limit=1&offset=2", body.Links.Next)
+}
+
+func TestArticleService_getHandler(t *testing.T) {
+	t.Parallel()
+
+	store := newTestArticleStorage(t, storage.Article{Title: "Foo", Slug: "old-foo"})
+	require.NoError(t, store.UpdateArticle(context.Background(), "old-foo", storage.Article{Title: "Foo", Slug: "foo"}))
+	service := NewArticleService(store, storage.CountExact)
+
+	r := chi.NewRouter()
+	r.Get("/articles/{slug}", service.getHandler)
+
+	tests := []struct {
+		name     string
+		target   string
+		code     int
+		location string
+	}{
+		{
+			name:   "found",
+			target: "/articles/foo",
+			code:   http.StatusOK,
+		},
+		{
+			name:   "not found",
+			target: "/articles/bar",
+			code:   http.StatusNotFound,
+		},
+		{
+			name:     "renamed",
+			target:   "/articles/old-foo?fields=title",
+			code:     http.StatusMovedPermanently,
+			location: "/articles/foo?fields=title",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+
+			r.ServeHTTP(w, req)
+
+			resp := w.Result()
+			assert.Equal(t, tt.code, resp.StatusCode)
+			assert.Equal(t, tt.location, resp.Header.Get("Location"))
+		})
+	}
+}
+
 func TestArticleService_deleteHandler(t *testing.T) {
 	t.Parallel()
 
-	service := NewArticleService(&mockArticleStorage{})
+	service := NewArticleService(newTestArticleStorage(t), storage.CountExact)
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodDelete, "/1", nil)
@@ -58,7 +246,7 @@ func TestArticleService_deleteHandler(t *testing.T) {
 func TestArticleService_storeHandler(t *testing.T) {
 	t.Parallel()
 
-	service := NewArticleService(&mockArticleStorage{})
+	service := NewArticleService(newTestArticleStorage(t), storage.CountExact)
 
 	r := chi.NewRouter()
 	r.Post("/{id}", service.storeHandler)
@@ -85,6 +273,20 @@ func TestArticleService_storeHandler(t *testing.T) {
 			}`,
 			code: http.StatusBadRequest,
 		},
+		{
+			name: "generated slug",
+			payload: `{
+				"title": "Новая книга"
+			}`,
+			code: http.StatusOK,
+		},
+		{
+			name: "title without letters",
+			payload: `{
+				"title": "???"
+			}`,
+			code: http.StatusBadRequest,
+		},
 		{
 			name: "no title",
 			payload: `{
@@ -125,28 +327,47 @@ func TestArticleService_storeHandler(t *testing.T) {
 	}
 }
 
-type mockArticleStorage struct {
-	data map[int]storage.Article
+// racingArticleStorage takes slug of the first created article right before it, like a concurrent request.
+type racingArticleStorage struct {
+	*memory.ArticleStorage
+	raced bool
 }
 
-func newMockArticleStorage(data map[int]storage.Article) *mockArticleStorage {
-	return &mockArticleStorage{
-		data: data,
+func (s *racingArticleStorage) CreateArticle(ctx context.Context, article storage.Article) error {
+	if !s.raced {
+		s.raced = true
+		if err := s.ArticleStorage.CreateArticle(ctx, storage.Article{Title: "Concurrent", Slug: article.Slug}); err != nil {
+			return err
+		}
 	}
+	return s.ArticleStorage.CreateArticle(ctx, article)
 }
 
-func (s *mockArticleStorage) FilterArticles(ctx context.Context, filter storage.ArticleFilter) ([]storage.Article, error) {
-	var res []storage.Article
-	for _, article := range s.data {
-		res = append(res, article)
-	}
-	return res, nil
-}
+func TestArticleService_storeHandler_slugRace(t *testing.T) {
+	t.Parallel()
+
+	store := &racingArticleStorage{ArticleStorage: newTestArticleStorage(t)}
+	service := NewArticleService(store, storage.CountExact)
 
-func (s *mockArticleStorage) StoreArticles(ctx context.Context, articles []storage.Article) error {
-	return nil
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"title": "Foo"}`))
+
+	r := chi.NewRouter()
+	r.Post("/", service.storeHandler)
+	r.ServeHTTP(w, req)
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "/foo-2", resp.Header.Get("Location"), "next free slug is tried")
+
+	article, err := store.GetArticle(context.Background(), "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "Concurrent", article.Title, "concurrent article is not overwritten")
 }
 
-func (s *mockArticleStorage) DeleteArticles(ctx context.Context, articles []storage.Article) error {
-	return nil
+func newTestArticleStorage(t *testing.T, articles ...storage.Article) *memory.ArticleStorage {
+	t.Helper()
+	store := memory.NewArticleStorage()
+	require.NoError(t, store.StoreArticles(context.Background(), articles))
+	return store
 }
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/agalitsyn/go-app/internal/pkg/response"
+)
+
+const rateLimitCleanupInterval = time.Minute
+
+// rateLimiter is a token bucket per client address, limits can be changed while it's in use.
+type rateLimiter struct {
+	mu          sync.Mutex
+	rate        float64
+	burst       float64
+	clients     map[string]*bucket
+	lastCleanup time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	l := &rateLimiter{clients: make(map[string]*bucket)}
+	l.setLimit(rate, burst)
+	return l
+}
+
+// setLimit changes requests per second and burst size, zero rate disables limiting.
+func (l *rateLimiter) setLimit(rate float64, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.burst = float64(burst)
+	l.clients = make(map[string]*bucket)
+}
+
+// allow takes a token for client and returns how long to wait if there is none.
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return true, 0
+	}
+	l.cleanup(now)
+
+	b, ok := l.clients[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.clients[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// cleanup forgets clients with full buckets, they are the same as new ones.
+func (l *rateLimiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < rateLimitCleanupInterval {
+		return
+	}
+	l.lastCleanup = now
+
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for client, b := range l.clients {
+		if now.Sub(b.last) > full {
+			delete(l.clients, client)
+		}
+	}
+}
+
+func (l *rateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := l.allow(clientAddr(r), time.Now())
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			response.MustRender(w, r, response.ErrTooManyRequests(errors.New("rate limit exceeded")))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientAddr returns client IP without port, RemoteAddr contains port unless it's set by RealIP middleware.
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goware/cors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter_allow(t *testing.T) {
+	t.Parallel()
+
+	l := newRateLimiter(1, 2)
+	now := time.Now()
+
+	ok, _ := l.allow("a", now)
+	assert.True(t, ok)
+	ok, _ = l.allow("a", now)
+	assert.True(t, ok)
+	ok, wait := l.allow("a", now)
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, wait)
+
+	ok, _ = l.allow("b", now)
+	assert.True(t, ok, "clients are limited separately")
+
+	ok, _ = l.allow("a", now.Add(time.Second))
+	assert.True(t, ok, "token is refilled")
+
+	l.setLimit(0, 0)
+	ok, _ = l.allow("a", now)
+	assert.True(t, ok, "zero rate disables limiting")
+}
+
+func TestRuntime_Apply(t *testing.T) {
+	t.Parallel()
+
+	rt := NewRuntime(cors.Options{AllowCredentials: true}, Settings{AllowedOrigins: []string{"https://a.example.com"}, RateLimit: 1, RateBurst: 1})
+	h := rt.corsHandler(rt.limiter.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	request := func(origin string) *http.Response {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Origin", origin)
+		h.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	resp := request("https://a.example.com")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "https://a.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+
+	resp = request("https://a.example.com")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+
+	rt.Apply(Settings{AllowedOrigins: []string{"https://b.example.com"}})
+
+	resp = request("https://a.example.com")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+
+	resp = request("https://b.example.com")
+	assert.Equal(t, "https://b.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/goware/cors"
+)
+
+// Settings can be changed while server is running.
+type Settings struct {
+	AllowedOrigins []string
+	// RateLimit is requests per second allowed for a client address, zero disables limiting.
+	RateLimit float64
+	RateBurst int
+}
+
+// Runtime holds middlewares which depend on Settings, so they can be updated without rebuilding router.
+type Runtime struct {
+	corsOptions cors.Options
+	cors        atomic.Value
+	limiter     *rateLimiter
+}
+
+// NewRuntime creates Runtime, allowed origins of corsOptions are replaced with ones from settings.
+func NewRuntime(corsOptions cors.Options, settings Settings) *Runtime {
+	rt := &Runtime{
+		corsOptions: corsOptions,
+		limiter:     newRateLimiter(settings.RateLimit, settings.RateBurst),
+	}
+	rt.setCORS(settings.AllowedOrigins)
+	return rt
+}
+
+// Apply replaces settings of running server.
+func (rt *Runtime) Apply(settings Settings) {
+	rt.setCORS(settings.AllowedOrigins)
+	rt.limiter.setLimit(settings.RateLimit, settings.RateBurst)
+}
+
+func (rt *Runtime) setCORS(origins []string) {
+	opts := rt.corsOptions
+	opts.AllowedOrigins = origins
+	rt.cors.Store(cors.New(opts))
+}
+
+func (rt *Runtime) corsHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt.cors.Load().(*cors.Cors).Handler(next).ServeHTTP(w, r)
+	})
+}
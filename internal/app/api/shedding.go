@@ -0,0 +1,120 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/agalitsyn/go-app/internal/pkg/response"
+)
+
+// sheddingInterval is how often the limit of load shedder is adjusted.
+const sheddingInterval = time.Second
+
+type SheddingOptions struct {
+	// MaxInFlight is the upper bound of concurrent requests, zero disables shedding.
+	MaxInFlight int
+	// MinInFlight is the lower bound of the limit while database is slow.
+	MinInFlight int
+	// MaxPoolWait is average time of waiting for database connection above which the limit is decreased.
+	MaxPoolWait time.Duration
+	// PoolWait returns average time of waiting for connection since the previous call, nil keeps the limit constant.
+	PoolWait func() time.Duration
+}
+
+type SheddingStats struct {
+	Limit    int   `json:"limit"`
+	InFlight int   `json:"in_flight"`
+	Shed     int64 `json:"shed"`
+}
+
+// LoadShedder rejects requests above the limit of concurrent ones, so they don't pile up waiting for database.
+// The limit is decreased while requests wait for database connections too long and slowly restored after that.
+type LoadShedder struct {
+	opts SheddingOptions
+	now  func() time.Time
+
+	mu         sync.Mutex
+	limit      int
+	inFlight   int
+	shed       int64
+	lastAdjust time.Time
+}
+
+func NewLoadShedder(opts SheddingOptions) *LoadShedder {
+	if opts.MinInFlight < 1 || opts.MinInFlight > opts.MaxInFlight {
+		opts.MinInFlight = opts.MaxInFlight
+	}
+	return &LoadShedder{opts: opts, now: time.Now, limit: opts.MaxInFlight}
+}
+
+func (l *LoadShedder) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.acquire() {
+			w.Header().Set("Retry-After", "1")
+			response.MustRender(w, r, response.ErrServiceUnavailable(errors.New("server is overloaded")))
+			return
+		}
+		defer l.release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *LoadShedder) acquire() bool {
+	if l.opts.MaxInFlight == 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.adjust(l.now())
+	if l.inFlight >= l.limit {
+		l.shed++
+		return false
+	}
+	l.inFlight++
+	return true
+}
+
+func (l *LoadShedder) release() {
+	if l.opts.MaxInFlight == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	l.inFlight--
+	l.mu.Unlock()
+}
+
+// adjust decreases the limit multiplicatively and increases additively, like TCP congestion control.
+func (l *LoadShedder) adjust(now time.Time) {
+	if l.opts.PoolWait == nil || now.Sub(l.lastAdjust) < sheddingInterval {
+		return
+	}
+	l.lastAdjust = now
+
+	if l.opts.PoolWait() > l.opts.MaxPoolWait {
+		l.limit = l.limit * 3 / 4
+		if l.limit < l.opts.MinInFlight {
+			l.limit = l.opts.MinInFlight
+		}
+		return
+	}
+
+	step := l.limit / 10
+	if step < 1 {
+		step = 1
+	}
+	l.limit += step
+	if l.limit > l.opts.MaxInFlight {
+		l.limit = l.opts.MaxInFlight
+	}
+}
+
+func (l *LoadShedder) Stats() SheddingStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return SheddingStats{Limit: l.limit, InFlight: l.inFlight, Shed: l.shed}
+}
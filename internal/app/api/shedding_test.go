@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadShedder_Handler(t *testing.T) {
+	t.Parallel()
+
+	l := NewLoadShedder(SheddingOptions{MaxInFlight: 1})
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-unblock
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	close(unblock)
+	<-done
+	assert.Equal(t, SheddingStats{Limit: 1, InFlight: 0, Shed: 1}, l.Stats())
+}
+
+func TestLoadShedder_adjust(t *testing.T) {
+	t.Parallel()
+
+	wait := 200 * time.Millisecond
+	l := NewLoadShedder(SheddingOptions{
+		MaxInFlight: 100,
+		MinInFlight: 50,
+		MaxPoolWait: 100 * time.Millisecond,
+		PoolWait:    func() time.Duration { return wait },
+	})
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	tick := func() {
+		now = now.Add(sheddingInterval)
+		require.True(t, l.acquire())
+		l.release()
+	}
+
+	tick()
+	assert.Equal(t, 75, l.Stats().Limit)
+	tick()
+	assert.Equal(t, 56, l.Stats().Limit)
+	tick()
+	assert.Equal(t, 50, l.Stats().Limit, "limit must not fall below minimum")
+
+	// Not adjusted more often than the interval.
+	require.True(t, l.acquire())
+	l.release()
+	assert.Equal(t, 50, l.Stats().Limit)
+
+	wait = 0
+	tick()
+	assert.Equal(t, 55, l.Stats().Limit)
+	for i := 0; i < 10; i++ {
+		tick()
+	}
+	assert.Equal(t, 100, l.Stats().Limit, "limit must not exceed maximum")
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/agalitsyn/go-app/internal/pkg/response"
+)
+
+// timeoutGrace is how long handler may take to return after the deadline.
+const timeoutGrace = 100 * time.Millisecond
+
+// withTimeout sets deadline of request context. If handler doesn't return in time, 503 is sent and its response
+// is discarded. If handler fails after the deadline, e.g. database query was canceled, its response
+// is replaced with 504. Response is buffered, so handler must not stream.
+func withTimeout(timeout time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			// Timeout responses are rendered with r, which changes it, so handler gets a copy.
+			tr := r.WithContext(ctx)
+			tw := &timeoutWriter{header: make(http.Header)}
+			done := make(chan struct{})
+			panicked := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				next.ServeHTTP(tw, tr)
+				close(done)
+			}()
+
+			select {
+			case p := <-panicked:
+				panic(p)
+			case <-done:
+			case <-ctx.Done():
+				// Handlers usually return shortly after the deadline, because their queries are canceled.
+				grace := time.NewTimer(timeoutGrace)
+				defer grace.Stop()
+				select {
+				case p := <-panicked:
+					panic(p)
+				case <-done:
+				case <-grace.C:
+					tw.mu.Lock()
+					tw.timedOut = true
+					tw.mu.Unlock()
+					// Nobody waits for response if client has gone.
+					if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+						err := fmt.Errorf("request timed out after %s", timeout)
+						response.MustRender(w, r, response.ErrServiceUnavailable(err))
+					}
+					return
+				}
+			}
+
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			if tw.code >= http.StatusInternalServerError && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				err := fmt.Errorf("request timed out after %s", timeout)
+				response.MustRender(w, r, response.ErrGatewayTimeout(err))
+				return
+			}
+			dst := w.Header()
+			for k, v := range tw.header {
+				dst[k] = v
+			}
+			if tw.code == 0 {
+				tw.code = http.StatusOK
+			}
+			w.WriteHeader(tw.code)
+			w.Write(tw.buf.Bytes())
+		})
+	}
+}
+
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	code     int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
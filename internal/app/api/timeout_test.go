@@ -0,0 +1,77 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/agalitsyn/go-app/internal/pkg/response"
+)
+
+func TestWithTimeout(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		code    int
+		body    string
+	}{
+		{
+			name: "in time",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Test", "1")
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprint(w, "ok")
+			},
+			code: http.StatusCreated,
+			body: "ok",
+		},
+		{
+			name: "failed after deadline",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+				response.MustRender(w, r, response.ErrUnknown(r.Context().Err()))
+			},
+			code: http.StatusGatewayTimeout,
+		},
+		{
+			name: "stuck",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(200 * time.Millisecond)
+				fmt.Fprint(w, "late")
+			},
+			code: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			withTimeout(20*time.Millisecond)(tt.handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.body != "" {
+				assert.Equal(t, tt.body, w.Body.String())
+				assert.Equal(t, "1", w.Header().Get("X-Test"))
+			}
+		})
+	}
+}
+
+func TestWithTimeout_panic(t *testing.T) {
+	t.Parallel()
+
+	h := withTimeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	assert.PanicsWithValue(t, "boom", func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
@@ -0,0 +1,210 @@
+// Package breaker implements circuit breaker, which rejects calls to a failing dependency,
+// so callers fail fast instead of piling up and the dependency has time to recover.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrOpen is matched by errors returned while breaker rejects calls, see OpenError.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// OpenError is returned instead of calling function while breaker is open.
+type OpenError struct {
+	// RetryAfter is time left until breaker lets probe calls through.
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrOpen, e.RetryAfter.Round(time.Millisecond))
+}
+
+func (e *OpenError) Is(target error) bool {
+	return target == ErrOpen
+}
+
+type State int
+
+const (
+	// Closed passes all calls and counts failures.
+	Closed State = iota
+	// Open rejects all calls.
+	Open
+	// HalfOpen passes a few probe calls, breaker closes if they succeed.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+type Options struct {
+	// FailureRatio of calls within Window opens breaker.
+	FailureRatio float64
+	// MinRequests within Window are required to open breaker, so a few failures don't open it.
+	MinRequests int
+	Window      time.Duration
+	// OpenTimeout is how long breaker stays open before probing.
+	OpenTimeout time.Duration
+	// HalfOpenRequests is number of successful probes which close breaker, they are executed one by one.
+	HalfOpenRequests int
+	// IsFailure reports if error of call is a failure of dependency, by default any error is.
+	IsFailure func(err error) bool
+}
+
+type Stats struct {
+	State    string `json:"state"`
+	Requests int    `json:"requests"`
+	Failures int    `json:"failures"`
+	// Opened is number of times breaker has opened.
+	Opened   int64 `json:"opened"`
+	Rejected int64 `json:"rejected"`
+}
+
+type Breaker struct {
+	opts Options
+	now  func() time.Time
+
+	mu          sync.Mutex
+	state       State
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	// probing is set while a half-open probe is in flight.
+	probing   bool
+	successes int
+	opened    int64
+	rejected  int64
+}
+
+func New(opts Options) *Breaker {
+	if opts.IsFailure == nil {
+		opts.IsFailure = func(err error) bool { return err != nil }
+	}
+	if opts.HalfOpenRequests < 1 {
+		opts.HalfOpenRequests = 1
+	}
+	return &Breaker{opts: opts, now: time.Now}
+}
+
+// Do calls fn unless breaker is open, result of fn is returned as is.
+func (b *Breaker) Do(fn func() error) error {
+	probe, err := b.allow()
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	b.record(probe, b.opts.IsFailure(err))
+	return err
+}
+
+func (b *Breaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if b.state == Open {
+		if wait := b.openedAt.Add(b.opts.OpenTimeout).Sub(now); wait > 0 {
+			b.rejected++
+			return false, &OpenError{RetryAfter: wait}
+		}
+		b.state = HalfOpen
+		b.successes = 0
+	}
+	if b.state == HalfOpen {
+		if b.probing {
+			b.rejected++
+			return false, &OpenError{RetryAfter: b.opts.OpenTimeout}
+		}
+		b.probing = true
+		return true, nil
+	}
+
+	if now.Sub(b.windowStart) >= b.opts.Window {
+		b.windowStart = now
+		b.requests, b.failures = 0, 0
+	}
+	return false, nil
+}
+
+func (b *Breaker) record(probe, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+		if failed {
+			b.open()
+			return
+		}
+		b.successes++
+		if b.successes >= b.opts.HalfOpenRequests {
+			b.state = Closed
+			b.windowStart = b.now()
+			b.requests, b.failures = 0, 0
+		}
+		return
+	}
+	// Calls started before breaker opened are not counted.
+	if b.state != Closed {
+		return
+	}
+
+	b.requests++
+	if failed {
+		b.failures++
+	}
+	if b.requests >= b.opts.MinRequests && float64(b.failures) >= b.opts.FailureRatio*float64(b.requests) && b.failures > 0 {
+		b.open()
+	}
+}
+
+func (b *Breaker) open() {
+	b.state = Open
+	b.openedAt = b.now()
+	b.opened++
+}
+
+// Check is health check failing while breaker rejects calls.
+func (b *Breaker) Check(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != Open {
+		return nil
+	}
+	if wait := b.openedAt.Add(b.opts.OpenTimeout).Sub(b.now()); wait > 0 {
+		return &OpenError{RetryAfter: wait}
+	}
+	return nil
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *Breaker) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return Stats{
+		State:    b.state.String(),
+		Requests: b.requests,
+		Failures: b.failures,
+		Opened:   b.opened,
+		Rejected: b.rejected,
+	}
+}
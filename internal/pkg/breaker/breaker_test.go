@@ -0,0 +1,89 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	b := New(Options{
+		FailureRatio:     0.5,
+		MinRequests:      4,
+		Window:           10 * time.Second,
+		OpenTimeout:      5 * time.Second,
+		HalfOpenRequests: 2,
+	})
+	b.now = func() time.Time { return now }
+
+	errFailed := errors.New("failed")
+	ok := func() error { return nil }
+	fail := func() error { return errFailed }
+
+	// 2 of 3 requests failed, but it's less than MinRequests.
+	assert.NoError(t, b.Do(ok))
+	assert.ErrorIs(t, b.Do(fail), errFailed)
+	assert.ErrorIs(t, b.Do(fail), errFailed)
+	assert.Equal(t, Closed, b.State())
+
+	assert.ErrorIs(t, b.Do(fail), errFailed)
+	assert.Equal(t, Open, b.State())
+	assert.Error(t, b.Check(context.Background()))
+
+	err := b.Do(ok)
+	var openErr *OpenError
+	require.True(t, errors.As(err, &openErr))
+	assert.True(t, errors.Is(err, ErrOpen))
+	assert.Equal(t, 5*time.Second, openErr.RetryAfter)
+
+	// Failed probe opens breaker again.
+	now = now.Add(5 * time.Second)
+	assert.ErrorIs(t, b.Do(fail), errFailed)
+	assert.Equal(t, Open, b.State())
+
+	now = now.Add(5 * time.Second)
+	assert.NoError(t, b.Do(ok))
+	assert.Equal(t, HalfOpen, b.State())
+	assert.NoError(t, b.Do(ok))
+	assert.Equal(t, Closed, b.State())
+	assert.NoError(t, b.Check(context.Background()))
+
+	assert.Equal(t, Stats{State: "closed", Opened: 2, Rejected: 1}, b.Stats())
+}
+
+func TestBreaker_window(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	b := New(Options{FailureRatio: 0.5, MinRequests: 2, Window: time.Second, OpenTimeout: time.Second})
+	b.now = func() time.Time { return now }
+
+	fail := func() error { return errors.New("failed") }
+	assert.Error(t, b.Do(fail))
+	now = now.Add(time.Second)
+	assert.Error(t, b.Do(fail))
+	assert.Equal(t, Closed, b.State(), "failures are counted within window")
+}
+
+func TestBreaker_halfOpenProbes(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	b := New(Options{FailureRatio: 0.5, MinRequests: 1, Window: time.Second, OpenTimeout: time.Second})
+	b.now = func() time.Time { return now }
+
+	assert.Error(t, b.Do(func() error { return errors.New("failed") }))
+	now = now.Add(time.Second)
+
+	err := b.Do(func() error {
+		return b.Do(func() error { return nil })
+	})
+	assert.ErrorIs(t, err, ErrOpen, "only one probe at a time")
+}
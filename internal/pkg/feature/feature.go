@@ -0,0 +1,44 @@
+// Package feature keeps feature flags, which can be toggled without restart.
+package feature
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	enabled = map[string]bool{}
+	mu      sync.RWMutex
+)
+
+// Enabled reports if feature flag is on.
+func Enabled(name string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return enabled[name]
+}
+
+// Set replaces enabled feature flags, flags missing in names are turned off.
+func Set(names []string) {
+	m := make(map[string]bool, len(names))
+	for _, name := range names {
+		m[name] = true
+	}
+
+	mu.Lock()
+	enabled = m
+	mu.Unlock()
+}
+
+// List returns sorted names of enabled feature flags.
+func List() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	res := make([]string, 0, len(enabled))
+	for name := range enabled {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
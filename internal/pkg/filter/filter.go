@@ -0,0 +1,97 @@
+// Package filter implements a small expression language for filtering resources, e.g.
+//
+//	title~"go" and id>100 or tag in ("a","b")
+//
+// Expressions are parsed into AST, validated against a Schema of allowed fields and operators
+// and then compiled by storage implementations into their own queries.
+package filter
+
+import (
+	"fmt"
+)
+
+type Operator string
+
+const (
+	OpEq       Operator = "="
+	OpNotEq    Operator = "!="
+	OpGt       Operator = ">"
+	OpGtOrEq   Operator = ">="
+	OpLt       Operator = "<"
+	OpLtOrEq   Operator = "<="
+	OpContains Operator = "~"
+	OpIn       Operator = "in"
+)
+
+// Expr is a node of the filter AST, one of *Logical, *Not or *Comparison.
+type Expr interface {
+	// Pos returns position of the expression in the input string, starting from 1.
+	Pos() int
+}
+
+type LogicalOp string
+
+const (
+	And LogicalOp = "and"
+	Or  LogicalOp = "or"
+)
+
+type Logical struct {
+	Op          LogicalOp
+	Left, Right Expr
+
+	pos int
+}
+
+func (e *Logical) Pos() int { return e.pos }
+
+type Not struct {
+	X Expr
+
+	pos int
+}
+
+func (e *Not) Pos() int { return e.pos }
+
+// Comparison compares field with values. Only OpIn has more than one value.
+// Values are string or int64 after validation against Schema.
+type Comparison struct {
+	Field  string
+	Op     Operator
+	Values []interface{}
+
+	pos int
+}
+
+func (e *Comparison) Pos() int { return e.pos }
+
+// Error describes invalid expression with position in the input string.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("filter: position %d: %s", e.Pos, e.Msg)
+}
+
+func errorf(pos int, format string, args ...interface{}) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Walk calls fn for every comparison in the expression.
+func Walk(e Expr, fn func(c *Comparison) error) error {
+	switch e := e.(type) {
+	case *Logical:
+		if err := Walk(e.Left, fn); err != nil {
+			return err
+		}
+		return Walk(e.Right, fn)
+	case *Not:
+		return Walk(e.X, fn)
+	case *Comparison:
+		return fn(e)
+	default:
+		return fmt.Errorf("filter: unknown expression %T", e)
+	}
+}
@@ -0,0 +1,288 @@
+package filter
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of input"
+	}
+	return strconv.Quote(t.text)
+}
+
+func (t token) isKeyword(kw string) bool {
+	return t.kind == tokenIdent && strings.EqualFold(t.text, kw)
+}
+
+type lexer struct {
+	input []rune
+	pos   int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.input) && unicode.IsSpace(l.input[l.pos]) {
+		l.pos++
+	}
+	if l.pos >= len(l.input) {
+		return token{kind: tokenEOF, pos: l.pos + 1}, nil
+	}
+
+	start := l.pos
+	c := l.input[l.pos]
+	switch {
+	case c == '(':
+		l.pos++
+		return token{kind: tokenLParen, text: "(", pos: start + 1}, nil
+	case c == ')':
+		l.pos++
+		return token{kind: tokenRParen, text: ")", pos: start + 1}, nil
+	case c == ',':
+		l.pos++
+		return token{kind: tokenComma, text: ",", pos: start + 1}, nil
+	case c == '"':
+		return l.string()
+	case c == '-' || unicode.IsDigit(c):
+		l.pos++
+		for l.pos < len(l.input) && unicode.IsDigit(l.input[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokenNumber, text: string(l.input[start:l.pos]), pos: start + 1}, nil
+	case c == '_' || unicode.IsLetter(c):
+		for l.pos < len(l.input) && (l.input[l.pos] == '_' || unicode.IsLetter(l.input[l.pos]) || unicode.IsDigit(l.input[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokenIdent, text: string(l.input[start:l.pos]), pos: start + 1}, nil
+	case strings.ContainsRune("=!<>~", c):
+		l.pos++
+		if l.pos < len(l.input) && l.input[l.pos] == '=' && c != '=' && c != '~' {
+			l.pos++
+		}
+		text := string(l.input[start:l.pos])
+		if text == "!" {
+			return token{}, errorf(start+1, "unexpected character %q", c)
+		}
+		return token{kind: tokenOperator, text: text, pos: start + 1}, nil
+	default:
+		return token{}, errorf(start+1, "unexpected character %q", c)
+	}
+}
+
+func (l *lexer) string() (token, error) {
+	start := l.pos
+	l.pos++ // opening quote
+
+	var sb strings.Builder
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		switch c {
+		case '"':
+			l.pos++
+			return token{kind: tokenString, text: sb.String(), pos: start + 1}, nil
+		case '\\':
+			if l.pos+1 >= len(l.input) {
+				return token{}, errorf(l.pos+1, "unterminated escape sequence")
+			}
+			l.pos++
+			sb.WriteRune(l.input[l.pos])
+		default:
+			sb.WriteRune(c)
+		}
+		l.pos++
+	}
+	return token{}, errorf(start+1, "unterminated string")
+}
+
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+// Parse parses the filter expression. Errors are reported as *Error with position.
+func Parse(input string) (Expr, error) {
+	p := &parser{lex: &lexer{input: []rune(input)}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokenEOF {
+		return nil, errorf(p.tok.pos, "empty expression")
+	}
+
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, errorf(p.tok.pos, "unexpected %s", p.tok)
+	}
+	return e, nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+// or := and ("or" and)*
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.isKeyword(string(Or)) {
+		pos := p.tok.pos
+		if err = p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: Or, Left: left, Right: right, pos: pos}
+	}
+	return left, nil
+}
+
+// and := unary ("and" unary)*
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.isKeyword(string(And)) {
+		pos := p.tok.pos
+		if err = p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: And, Left: left, Right: right, pos: pos}
+	}
+	return left, nil
+}
+
+// unary := "not" unary | "(" or ")" | comparison
+func (p *parser) parseUnary() (Expr, error) {
+	switch {
+	case p.tok.isKeyword("not"):
+		pos := p.tok.pos
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: x, pos: pos}, nil
+	case p.tok.kind == tokenLParen:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokenRParen {
+			return nil, errorf(p.tok.pos, "expected \")\", got %s", p.tok)
+		}
+		return e, p.advance()
+	default:
+		return p.parseComparison()
+	}
+}
+
+// comparison := field operator value | field "in" "(" value ("," value)* ")"
+func (p *parser) parseComparison() (Expr, error) {
+	if p.tok.kind != tokenIdent {
+		return nil, errorf(p.tok.pos, "expected field name, got %s", p.tok)
+	}
+	c := &Comparison{Field: p.tok.text, pos: p.tok.pos}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.isKeyword(string(OpIn)) {
+		c.Op = OpIn
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokenLParen {
+			return nil, errorf(p.tok.pos, "expected \"(\", got %s", p.tok)
+		}
+		for {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			c.Values = append(c.Values, v)
+
+			if p.tok.kind == tokenRParen {
+				break
+			}
+			if p.tok.kind != tokenComma {
+				return nil, errorf(p.tok.pos, "expected \",\" or \")\", got %s", p.tok)
+			}
+		}
+		return c, p.advance()
+	}
+
+	if p.tok.kind != tokenOperator {
+		return nil, errorf(p.tok.pos, "expected operator, got %s", p.tok)
+	}
+	c.Op = Operator(p.tok.text)
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	c.Values = []interface{}{v}
+	return c, nil
+}
+
+func (p *parser) parseValue() (interface{}, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokenString:
+		return tok.text, p.advance()
+	case tokenNumber:
+		n, err := strconv.ParseInt(tok.text, 10, 64)
+		if err != nil {
+			return nil, errorf(tok.pos, "invalid number %s", tok)
+		}
+		return n, p.advance()
+	default:
+		return nil, errorf(tok.pos, "expected value, got %s", tok)
+	}
+}
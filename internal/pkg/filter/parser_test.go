@@ -0,0 +1,111 @@
+package filter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	e, err := Parse(`title~"go" and id>100 or tag in ("a","b")`)
+	require.NoError(t, err)
+
+	or, ok := e.(*Logical)
+	require.True(t, ok)
+	assert.Equal(t, Or, or.Op)
+
+	and, ok := or.Left.(*Logical)
+	require.True(t, ok)
+	assert.Equal(t, And, and.Op)
+	assert.Equal(t, &Comparison{Field: "title", Op: OpContains, Values: []interface{}{"go"}, pos: 1}, and.Left)
+	assert.Equal(t, &Comparison{Field: "id", Op: OpGt, Values: []interface{}{int64(100)}, pos: 16}, and.Right)
+
+	assert.Equal(t, &Comparison{Field: "tag", Op: OpIn, Values: []interface{}{"a", "b"}, pos: 26}, or.Right)
+}
+
+func TestParse_precedence(t *testing.T) {
+	t.Parallel()
+
+	e, err := Parse(`not (id = 1 or id = 2) and slug != "foo \"bar\""`)
+	require.NoError(t, err)
+
+	and, ok := e.(*Logical)
+	require.True(t, ok)
+	assert.Equal(t, And, and.Op)
+
+	not, ok := and.Left.(*Not)
+	require.True(t, ok)
+	_, ok = not.X.(*Logical)
+	assert.True(t, ok)
+
+	c, ok := and.Right.(*Comparison)
+	require.True(t, ok)
+	assert.Equal(t, []interface{}{`foo "bar"`}, c.Values)
+}
+
+func TestParse_errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input string
+		pos   int
+	}{
+		{input: ``, pos: 1},
+		{input: `title`, pos: 6},
+		{input: `title = `, pos: 9},
+		{input: `title = "go`, pos: 9},
+		{input: `id > 1 and`, pos: 11},
+		{input: `id > 1 foo`, pos: 8},
+		{input: `(id > 1`, pos: 8},
+		{input: `tag in ("a" "b")`, pos: 13},
+		{input: `id ! 1`, pos: 4},
+		{input: `id = 1 & id = 2`, pos: 8},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			require.Error(t, err)
+
+			var filterErr *Error
+			require.True(t, errors.As(err, &filterErr))
+			assert.Equal(t, tt.pos, filterErr.Pos)
+		})
+	}
+}
+
+func TestSchema_Validate(t *testing.T) {
+	t.Parallel()
+
+	schema := Schema{
+		"id":    {Type: Int, Operators: IntOperators},
+		"title": {Type: String, Operators: StringOperators},
+	}
+
+	tests := []struct {
+		input string
+		valid bool
+	}{
+		{input: `id >= 1 and title ~ "go"`, valid: true},
+		{input: `id in (1, 2, 3)`, valid: true},
+		{input: `slug = "go"`},
+		{input: `title > "go"`},
+		{input: `id = "1"`},
+		{input: `title in ("a", 1)`},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := ParseWithSchema(tt.input, schema)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package filter
+
+type ValueType int
+
+const (
+	String ValueType = iota
+	Int
+)
+
+func (t ValueType) String() string {
+	if t == Int {
+		return "integer"
+	}
+	return "string"
+}
+
+// Field describes a filterable field and operators allowed for it.
+type Field struct {
+	Type      ValueType
+	Operators []Operator
+}
+
+var (
+	StringOperators = []Operator{OpEq, OpNotEq, OpContains, OpIn}
+	IntOperators    = []Operator{OpEq, OpNotEq, OpGt, OpGtOrEq, OpLt, OpLtOrEq, OpIn}
+)
+
+// Schema is a whitelist of fields which can be used in resource filter.
+type Schema map[string]Field
+
+// Validate checks that expression uses only known fields, allowed operators and values of the right type.
+func (s Schema) Validate(e Expr) error {
+	return Walk(e, func(c *Comparison) error {
+		field, ok := s[c.Field]
+		if !ok {
+			return errorf(c.Pos(), "unknown field %q", c.Field)
+		}
+
+		allowed := false
+		for _, op := range field.Operators {
+			if op == c.Op {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return errorf(c.Pos(), "operator %q is not allowed for field %q", c.Op, c.Field)
+		}
+
+		for _, v := range c.Values {
+			var valid bool
+			switch v.(type) {
+			case string:
+				valid = field.Type == String
+			case int64:
+				valid = field.Type == Int
+			}
+			if !valid {
+				return errorf(c.Pos(), "field %q expects %s value", c.Field, field.Type)
+			}
+		}
+		return nil
+	})
+}
+
+// ParseWithSchema parses expression and validates it against schema.
+func ParseWithSchema(input string, schema Schema) (Expr, error) {
+	e, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	if err = schema.Validate(e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -0,0 +1,227 @@
+package flag
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/jessevdk/go-flags"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigOption is the long name of option with path to config file.
+const ConfigOption = "config"
+
+// SecretFileSuffix is appended to env name of an option to read its value from file, e.g. POSTGRES_URL_FILE.
+const SecretFileSuffix = "_FILE"
+
+const redacted = "xxxxx"
+
+// loadDefaults replaces default values of options with values from config file and secret files.
+// Environment variables and command line are applied later by parser, so the precedence is:
+// command line > environment > *_FILE > config file > default.
+func loadDefaults(parser *flags.Parser, args []string) error {
+	path := configPath(parser, args)
+	if path != "" {
+		values, err := readConfigFile(path)
+		if err != nil {
+			return err
+		}
+		for key, value := range values {
+			option := parser.FindOptionByLongName(key)
+			if option == nil || skipConfig(option) {
+				return fmt.Errorf("unknown option %q in config file %s", key, path)
+			}
+			option.Default = value
+		}
+	}
+
+	var err error
+	eachOption([]*flags.Group{parser.Command.Group}, func(option *flags.Option) {
+		if err != nil || option.EnvDefaultKey == "" {
+			return
+		}
+		fileKey := option.EnvDefaultKey + SecretFileSuffix
+		file, ok := os.LookupEnv(fileKey)
+		if !ok {
+			return
+		}
+		if _, ok = os.LookupEnv(option.EnvDefaultKey); ok {
+			err = fmt.Errorf("both %s and %s are set", option.EnvDefaultKey, fileKey)
+			return
+		}
+
+		var content []byte
+		if content, err = os.ReadFile(file); err != nil {
+			err = fmt.Errorf("could not read %s: %w", fileKey, err)
+			return
+		}
+		option.Default = []string{strings.TrimRight(string(content), "\r\n")}
+	})
+	return err
+}
+
+// configPath looks for config file option in args and environment before parsing,
+// because values from the file are defaults for the parser.
+func configPath(parser *flags.Parser, args []string) string {
+	option := parser.FindOptionByLongName(ConfigOption)
+	if option == nil {
+		return ""
+	}
+
+	flag := "--" + ConfigOption
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if arg == flag && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(arg, flag+"=") {
+			return strings.TrimPrefix(arg, flag+"=")
+		}
+	}
+
+	if path, ok := os.LookupEnv(option.EnvDefaultKey); ok && option.EnvDefaultKey != "" {
+		return path
+	}
+	if len(option.Default) > 0 {
+		return option.Default[0]
+	}
+	return ""
+}
+
+// readConfigFile reads YAML or TOML file, depending on extension, with long option names as keys.
+func readConfigFile(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file: %w", err)
+	}
+
+	raw := make(map[string]interface{})
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q, use .yaml or .toml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string][]string, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = items
+		case map[string]interface{}:
+			return nil, fmt.Errorf("option %q in config file %s must be a value or a list", key, path)
+		case nil:
+			values[key] = nil
+		default:
+			values[key] = []string{fmt.Sprint(v)}
+		}
+	}
+	return values, nil
+}
+
+// WriteConfig writes options of cfg as YAML which can be used as config file.
+// Values of options tagged with secret are redacted.
+func WriteConfig(w io.Writer, cfg interface{}) error {
+	parser := flags.NewParser(cfg, flags.None)
+
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	var err error
+	eachOption([]*flags.Group{parser.Command.Group}, func(option *flags.Option) {
+		if err != nil || skipConfig(option) || option.LongName == "" {
+			return
+		}
+
+		value := option.Value()
+		// Durations would be written as nanoseconds otherwise.
+		if s, ok := value.(fmt.Stringer); ok {
+			value = s.String()
+		}
+		if _, ok := option.Field().Tag.Lookup("secret"); ok {
+			value = redactValue(value)
+		}
+
+		var data []byte
+		if data, err = yaml.Marshal(value); err != nil {
+			return
+		}
+		var node yaml.Node
+		if err = yaml.Unmarshal(data, &node); err != nil {
+			return
+		}
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: option.LongName}, node.Content[0])
+	})
+	if err != nil {
+		return fmt.Errorf("could not encode config: %w", err)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err = enc.Encode(doc); err != nil {
+		return fmt.Errorf("could not encode config: %w", err)
+	}
+	return enc.Close()
+}
+
+// Redact hides password of URL, both in user info and password query parameter
+// like in PostgreSQL connection strings, or the whole value otherwise.
+func Redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return redacted
+	}
+	q := u.Query()
+	_, hasPassword := q["password"]
+	if u.User == nil && !hasPassword {
+		return redacted
+	}
+	if hasPassword {
+		q.Set("password", redacted)
+		u.RawQuery = q.Encode()
+	}
+	return u.Redacted()
+}
+
+func redactValue(value interface{}) interface{} {
+	list, ok := value.([]string)
+	if !ok {
+		return Redact(fmt.Sprint(value))
+	}
+	res := make([]string, 0, len(list))
+	for _, v := range list {
+		res = append(res, Redact(v))
+	}
+	return res
+}
+
+// skipConfig reports if option can't be set in config file, it uses the same tag as go-flags ini parser.
+func skipConfig(option *flags.Option) bool {
+	return option.LongName == ConfigOption || option.Field().Tag.Get("no-ini") != ""
+}
+
+func eachOption(groups []*flags.Group, fn func(option *flags.Option)) {
+	for _, group := range groups {
+		for _, option := range group.Options() {
+			fn(option)
+		}
+		eachOption(group.Groups(), fn)
+	}
+}
@@ -0,0 +1,51 @@
+package flag
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// Change describes option which differs between two configurations.
+type Change struct {
+	Option string
+	Old    string
+	New    string
+	// Reloadable is set for options tagged with reload, which can be applied without restart.
+	Reloadable bool
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Option, c.Old, c.New)
+}
+
+// Diff compares options of two configurations of the same type, values of secret options are redacted.
+func Diff(old, new interface{}) []Change {
+	oldOptions := make(map[string]*flags.Option)
+	eachOption([]*flags.Group{flags.NewParser(old, flags.None).Command.Group}, func(option *flags.Option) {
+		oldOptions[option.LongName] = option
+	})
+
+	var changes []Change
+	eachOption([]*flags.Group{flags.NewParser(new, flags.None).Command.Group}, func(option *flags.Option) {
+		prev, ok := oldOptions[option.LongName]
+		if !ok {
+			return
+		}
+		// Values are compared as printed, so nil and empty lists are equal.
+		change := Change{
+			Option:     option.LongName,
+			Old:        fmt.Sprint(prev.Value()),
+			New:        fmt.Sprint(option.Value()),
+			Reloadable: option.Field().Tag.Get("reload") != "",
+		}
+		if change.Old == change.New {
+			return
+		}
+		if _, ok := option.Field().Tag.Lookup("secret"); ok {
+			change.Old, change.New = Redact(change.Old), Redact(change.New)
+		}
+		changes = append(changes, change)
+	})
+	return changes
+}
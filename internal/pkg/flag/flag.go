@@ -15,11 +15,17 @@ func GetVersion() string {
 	return version
 }
 
-func ParseFlags(cfg interface{}) {
-	parser := flags.NewParser(cfg, flags.Default)
-	if _, err := parser.Parse(); err != nil {
-		if flagsErr, ok := err.(*flags.Error); ok && flagsErr.Type == flags.ErrHelp {
-			os.Exit(0)
+// ParseFlags parses command line into cfg and returns path of the invoked command,
+// e.g. ["migrate", "up"], or nil if no command was given.
+func ParseFlags(cfg interface{}) []string {
+	command, err := parse(cfg, os.Args[1:], flags.Default)
+	if err != nil {
+		if flagsErr, ok := err.(*flags.Error); ok {
+			if flagsErr.Type == flags.ErrHelp {
+				os.Exit(0)
+			}
+		} else {
+			fmt.Fprintln(os.Stderr, err)
 		}
 		os.Exit(1)
 	}
@@ -29,4 +35,28 @@ func ParseFlags(cfg interface{}) {
 		fmt.Fprintln(os.Stdout, GetVersion())
 		os.Exit(0)
 	}
+
+	return command
+}
+
+// Parse is ParseFlags which returns errors instead of printing them and exiting, e.g. for reloading configuration.
+func Parse(cfg interface{}, args []string) ([]string, error) {
+	return parse(cfg, args, flags.PassDoubleDash)
+}
+
+func parse(cfg interface{}, args []string, options flags.Options) ([]string, error) {
+	parser := flags.NewParser(cfg, options)
+	parser.SubcommandsOptional = true
+	if err := loadDefaults(parser, args); err != nil {
+		return nil, err
+	}
+	if _, err := parser.ParseArgs(args); err != nil {
+		return nil, err
+	}
+
+	var command []string
+	for active := parser.Active; active != nil; active = active.Active {
+		command = append(command, active.Name)
+	}
+	return command, nil
 }
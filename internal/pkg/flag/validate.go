@@ -0,0 +1,99 @@
+package flag
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError contains all problems found in configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Validator collects configuration problems, so they are reported at once instead of one per run.
+// Names are option names as user sees them, e.g. --addr.
+type Validator struct {
+	problems []string
+}
+
+// Check adds problem if ok is false.
+func (v *Validator) Check(ok bool, name, format string, args ...interface{}) {
+	if !ok {
+		v.problems = append(v.problems, name+": "+fmt.Sprintf(format, args...))
+	}
+}
+
+// Addr checks host:port listen address, host may be empty.
+func (v *Validator) Addr(name, value string) {
+	_, port, err := net.SplitHostPort(value)
+	if err != nil {
+		v.Check(false, name, "invalid address %q: %s", value, err)
+		return
+	}
+	n, err := strconv.ParseUint(port, 10, 16)
+	v.Check(err == nil && (n > 0 || port == "0"), name, "invalid port %q", port)
+}
+
+// URL checks absolute URL with one of schemes.
+func (v *Validator) URL(name, value string, schemes ...string) {
+	u, err := url.Parse(value)
+	if err != nil {
+		v.Check(false, name, "invalid URL: %s", redactError(err, value))
+		return
+	}
+	v.Check(u.Host != "", name, "URL must contain host")
+	if len(schemes) > 0 {
+		v.Check(contains(schemes, u.Scheme), name, "URL scheme must be one of %s", strings.Join(schemes, ", "))
+	}
+}
+
+// Origin checks CORS origin, which is either * or scheme://host[:port] where host may contain one wildcard.
+func (v *Validator) Origin(name, value string) {
+	if value == "*" {
+		return
+	}
+
+	u, err := url.Parse(strings.Replace(value, "*", "wildcard", 1))
+	valid := err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		u.User == nil && (u.Path == "" || u.Path == "/") && u.RawQuery == "" && u.Fragment == ""
+	v.Check(valid, name, "invalid origin %q, expected scheme://host[:port]", value)
+	v.Check(strings.Count(value, "*") <= 1, name, "origin %q may contain only one wildcard", value)
+}
+
+// Duration checks that d is within [min, max], zero max means no upper limit.
+func (v *Validator) Duration(name string, d, min, max time.Duration) {
+	v.Check(d >= min, name, "must be at least %s", min)
+	if max > 0 {
+		v.Check(d <= max, name, "must be at most %s", max)
+	}
+}
+
+// Err returns *ValidationError with all problems or nil.
+func (v *Validator) Err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// redactError removes value from error message, because it may contain password.
+func redactError(err error, value string) string {
+	return strings.ReplaceAll(err.Error(), value, Redact(value))
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package flag
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidator(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		check func(v *Validator)
+		valid bool
+	}{
+		{name: "addr", check: func(v *Validator) { v.Addr("--addr", "localhost:8080") }, valid: true},
+		{name: "addr without host", check: func(v *Validator) { v.Addr("--addr", ":8080") }, valid: true},
+		{name: "addr without port", check: func(v *Validator) { v.Addr("--addr", "localhost") }},
+		{name: "addr with bad port", check: func(v *Validator) { v.Addr("--addr", "localhost:http") }},
+		{name: "addr with large port", check: func(v *Validator) { v.Addr("--addr", "localhost:70000") }},
+		{name: "url", check: func(v *Validator) { v.URL("--url", "postgres://u:p@localhost/db", "postgres") }, valid: true},
+		{name: "url scheme", check: func(v *Validator) { v.URL("--url", "mysql://localhost/db", "postgres") }},
+		{name: "url without host", check: func(v *Validator) { v.URL("--url", "localhost/db") }},
+		{name: "origin", check: func(v *Validator) { v.Origin("--origin", "https://example.com:8443") }, valid: true},
+		{name: "origin any", check: func(v *Validator) { v.Origin("--origin", "*") }, valid: true},
+		{name: "origin wildcard", check: func(v *Validator) { v.Origin("--origin", "https://*.example.com") }, valid: true},
+		{name: "origin without scheme", check: func(v *Validator) { v.Origin("--origin", "example.com") }},
+		{name: "origin with path", check: func(v *Validator) { v.Origin("--origin", "https://example.com/app") }},
+		{name: "duration", check: func(v *Validator) { v.Duration("--timeout", time.Second, 0, time.Minute) }, valid: true},
+		{name: "duration negative", check: func(v *Validator) { v.Duration("--timeout", -time.Second, 0, 0) }},
+		{name: "duration too long", check: func(v *Validator) { v.Duration("--timeout", time.Hour, 0, time.Minute) }},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var v Validator
+			tt.check(&v)
+			if tt.valid {
+				assert.NoError(t, v.Err())
+			} else {
+				assert.Error(t, v.Err())
+			}
+		})
+	}
+}
+
+func TestValidator_Err(t *testing.T) {
+	t.Parallel()
+
+	var v Validator
+	v.Addr("--addr", "localhost")
+	v.URL("--postgres-url", "postgres://user:secret@/db")
+	v.Check(false, "--rate-burst", "must be at least 1")
+
+	err := v.Err()
+	require.Error(t, err)
+
+	verr, ok := err.(*ValidationError)
+	require.True(t, ok)
+	assert.Len(t, verr.Problems, 3)
+	assert.NotContains(t, err.Error(), "secret")
+}
@@ -0,0 +1,211 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// Probe is a kind of health endpoint, a check can be used in several of them.
+type Probe int
+
+const (
+	// Liveness fails if the process must be restarted.
+	Liveness Probe = 1 << iota
+	// Readiness fails if the process must not receive requests.
+	Readiness
+	// Startup fails until the process has started.
+	Startup
+)
+
+const (
+	defaultCheckTimeout = time.Second
+	statusOK            = "ok"
+	statusFailed        = "failed"
+)
+
+// CheckFunc reports health of a dependency, nil means healthy.
+type CheckFunc func(ctx context.Context) error
+
+// CheckOptions of check registration.
+type CheckOptions struct {
+	Probes Probe
+	// Timeout of a single run, one second by default.
+	Timeout time.Duration
+	// CacheTTL is how long the last result is reused, so frequent probes don't load dependencies.
+	CacheTTL time.Duration
+}
+
+// Result of the last check run.
+type Result struct {
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	LatencyMs   float64    `json:"latency_ms"`
+	CheckedAt   time.Time  `json:"checked_at"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+	opts CheckOptions
+
+	// mu serializes runs, so concurrent probes wait for one run instead of running the check in parallel.
+	mu     sync.Mutex
+	result Result
+	err    error
+}
+
+// Registry keeps named checks and serves probe endpoints.
+type Registry struct {
+	mu     sync.RWMutex
+	checks []*check
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds check, names must be unique.
+func (r *Registry) Register(name string, fn CheckFunc, opts CheckOptions) {
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultCheckTimeout
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.checks {
+		if c.name == name {
+			panic(fmt.Sprintf("health: check %s is already registered", name))
+		}
+	}
+	r.checks = append(r.checks, &check{name: name, fn: fn, opts: opts})
+}
+
+// Run runs checks of the probe except excluded ones and reports if all of them passed.
+func (r *Registry) Run(ctx context.Context, probe Probe, exclude map[string]bool) ([]Result, bool) {
+	r.mu.RLock()
+	var checks []*check
+	for _, c := range r.checks {
+		if c.opts.Probes&probe != 0 && !exclude[c.name] {
+			checks = append(checks, c)
+		}
+	}
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *check) {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+
+	healthy := true
+	for _, res := range results {
+		if res.Status != statusOK {
+			healthy = false
+		}
+	}
+	return results, healthy
+}
+
+func (c *check) run(ctx context.Context) Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if !c.result.CheckedAt.IsZero() && now.Sub(c.result.CheckedAt) < c.opts.CacheTTL {
+		return c.result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+	err := c.fn(ctx)
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+
+	c.result.Name = c.name
+	c.result.CheckedAt = now
+	c.result.LatencyMs = float64(time.Since(now).Microseconds()) / 1000
+	c.result.Status = statusOK
+	c.result.Error = ""
+	if err != nil {
+		c.result.Status = statusFailed
+		c.result.Error = err.Error()
+		c.result.LastError = err.Error()
+		c.result.LastErrorAt = &now
+	}
+	return c.result
+}
+
+type probeResponse struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks,omitempty"`
+}
+
+// Handler serves probe with status 200 or 503. Failed checks are always listed, all checks are listed
+// with ?verbose. Checks are skipped with ?exclude=name, which can be repeated or comma separated.
+func (r *Registry) Handler(probe Probe) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		exclude := make(map[string]bool)
+		for _, v := range q["exclude"] {
+			for _, name := range strings.Split(v, ",") {
+				exclude[strings.TrimSpace(name)] = true
+			}
+		}
+		_, verbose := q["verbose"]
+
+		results, healthy := r.Run(req.Context(), probe, exclude)
+
+		resp := probeResponse{Status: statusOK}
+		status := http.StatusOK
+		if !healthy {
+			resp.Status = statusFailed
+			status = http.StatusServiceUnavailable
+		}
+		for _, res := range results {
+			if verbose || res.Status != statusOK {
+				resp.Checks = append(resp.Checks, res)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// RegisterRoutes adds /livez, /readyz and /startupz to router.
+func (r *Registry) RegisterRoutes(router chi.Router) {
+	router.Get("/livez", r.Handler(Liveness))
+	router.Get("/readyz", r.Handler(Readiness))
+	router.Get("/startupz", r.Handler(Startup))
+}
+
+// ReadinessCheck reports status set with SetReadinessStatus, e.g. during shutdown.
+// Unready reasons are not included, they are expected to have own checks.
+func ReadinessCheck(ctx context.Context) error {
+	mu.RLock()
+	status := readinessStatus
+	mu.RUnlock()
+
+	if status != http.StatusOK {
+		return fmt.Errorf("readiness status is %d", status)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Handler(t *testing.T) {
+	t.Parallel()
+
+	checks := NewRegistry()
+	checks.Register("ok", func(ctx context.Context) error { return nil }, CheckOptions{Probes: Liveness | Readiness})
+	checks.Register("db", func(ctx context.Context) error { return errors.New("connection refused") }, CheckOptions{Probes: Readiness})
+	checks.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, CheckOptions{Probes: Startup, Timeout: 10 * time.Millisecond})
+
+	r := chi.NewRouter()
+	checks.RegisterRoutes(r)
+
+	tests := []struct {
+		name   string
+		target string
+		code   int
+		checks []string
+	}{
+		{name: "live", target: "/livez", code: http.StatusOK},
+		{name: "live verbose", target: "/livez?verbose", code: http.StatusOK, checks: []string{"ok"}},
+		{name: "ready", target: "/readyz", code: http.StatusServiceUnavailable, checks: []string{"db"}},
+		{name: "ready verbose", target: "/readyz?verbose", code: http.StatusServiceUnavailable, checks: []string{"ok", "db"}},
+		{name: "ready exclude", target: "/readyz?exclude=db", code: http.StatusOK},
+		{name: "startup timeout", target: "/startupz", code: http.StatusServiceUnavailable, checks: []string{"slow"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			resp := w.Result()
+			assert.Equal(t, tt.code, resp.StatusCode)
+
+			var body probeResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			var names []string
+			for _, res := range body.Checks {
+				names = append(names, res.Name)
+			}
+			assert.Equal(t, tt.checks, names)
+		})
+	}
+}
+
+func TestRegistry_cache(t *testing.T) {
+	t.Parallel()
+
+	var (
+		calls int32
+		fail  int32 = 1
+	)
+	checks := NewRegistry()
+	checks.Register("db", func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&fail) == 1 {
+			return errors.New("down")
+		}
+		return nil
+	}, CheckOptions{Probes: Readiness, CacheTTL: 20 * time.Millisecond})
+
+	_, healthy := checks.Run(context.Background(), Readiness, nil)
+	assert.False(t, healthy)
+	atomic.StoreInt32(&fail, 0)
+	_, healthy = checks.Run(context.Background(), Readiness, nil)
+	assert.False(t, healthy, "cached result is used")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	time.Sleep(30 * time.Millisecond)
+	results, healthy := checks.Run(context.Background(), Readiness, nil)
+	assert.True(t, healthy)
+	require.Len(t, results, 1)
+	assert.Empty(t, results[0].Error)
+	assert.Equal(t, "down", results[0].LastError)
+	assert.NotNil(t, results[0].LastErrorAt)
+}
+
+func TestHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	h := NewHeartbeat(time.Millisecond)
+	assert.NoError(t, h.Check(context.Background()), "healthy before start")
+
+	h.Start()
+	time.Sleep(5 * time.Millisecond)
+	assert.Error(t, h.Check(context.Background()))
+
+	h.Beat()
+	assert.NoError(t, h.Check(context.Background()))
+
+	h.Stop()
+	time.Sleep(5 * time.Millisecond)
+	assert.NoError(t, h.Check(context.Background()))
+}
+
+func TestSetUnready(t *testing.T) {
+	SetUnready("test", errors.New("down"))
+	assert.Equal(t, http.StatusServiceUnavailable, ReadinessStatus())
+	assert.NoError(t, ReadinessCheck(context.Background()), "unready reasons have own checks")
+
+	SetUnready("test", nil)
+	assert.Equal(t, http.StatusOK, ReadinessStatus())
+}
@@ -7,12 +7,18 @@ import (
 
 var (
 	readinessStatus = http.StatusOK
-	mu              sync.RWMutex
+	// unready contains reasons of temporary unreadiness, e.g. lost database connection.
+	unready = map[string]error{}
+	mu      sync.RWMutex
 )
 
+// ReadinessStatus returns status set with SetReadinessStatus or 503 if there are unready reasons.
 func ReadinessStatus() int {
 	mu.RLock()
 	defer mu.RUnlock()
+	if readinessStatus == http.StatusOK && len(unready) > 0 {
+		return http.StatusServiceUnavailable
+	}
 	return readinessStatus
 }
 
@@ -21,3 +27,15 @@ func SetReadinessStatus(status int) {
 	readinessStatus = status
 	mu.Unlock()
 }
+
+// SetUnready marks service unready because of reason, nil err clears the reason.
+// Unlike SetReadinessStatus it allows independent components to report their state.
+func SetUnready(reason string, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if err == nil {
+		delete(unready, reason)
+	} else {
+		unready[reason] = err
+	}
+}
@@ -0,0 +1,62 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// heartbeatTolerance is how many intervals can be missed before worker is considered stuck.
+const heartbeatTolerance = 3
+
+// Heartbeat is beaten by a periodic background worker, its check fails if the worker got stuck.
+// It's healthy while worker is not running.
+type Heartbeat struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	last    time.Time
+	running bool
+}
+
+// NewHeartbeat creates heartbeat of worker doing something every interval.
+func NewHeartbeat(interval time.Duration) *Heartbeat {
+	return &Heartbeat{interval: interval}
+}
+
+// Start is called when worker starts.
+func (h *Heartbeat) Start() {
+	h.mu.Lock()
+	h.running = true
+	h.last = time.Now()
+	h.mu.Unlock()
+}
+
+// Beat is called by worker on every iteration.
+func (h *Heartbeat) Beat() {
+	h.mu.Lock()
+	h.last = time.Now()
+	h.mu.Unlock()
+}
+
+// Stop is called when worker has finished.
+func (h *Heartbeat) Stop() {
+	h.mu.Lock()
+	h.running = false
+	h.mu.Unlock()
+}
+
+// Check is CheckFunc failing if there were no beats for several intervals.
+func (h *Heartbeat) Check(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.running {
+		return nil
+	}
+	if age := time.Since(h.last); age > heartbeatTolerance*h.interval {
+		return fmt.Errorf("no heartbeat for %s", age.Round(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+// Package lifecycle starts and stops application components in order of their dependencies.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agalitsyn/go-app/internal/pkg/log"
+)
+
+// Component is a part of application with managed lifetime, e.g. database pool, server or scheduler.
+// Start must return soon after ctx is done, start timeout is enforced through it.
+type Component interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// Policy defines what happens if component fails to start.
+type Policy int
+
+const (
+	// FailFast stops already started components and fails application start.
+	FailFast Policy = iota
+	// Degraded logs the error and starts the rest of application, readiness is reported by health checks
+	// of the component. Components depending on it are not started and handled according to their own policies.
+	Degraded
+)
+
+func (p Policy) String() string {
+	if p == Degraded {
+		return "degraded"
+	}
+	return "fail-fast"
+}
+
+// Options of component registration.
+type Options struct {
+	// DependsOn lists names of components which must be started before and stopped after this one.
+	DependsOn []string
+	Policy    Policy
+	// StartTimeout overrides default start timeout of Manager.
+	StartTimeout time.Duration
+}
+
+// Hooks adapts functions to Component, nil functions are no-op.
+type Hooks struct {
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+func (h Hooks) Start(ctx context.Context) error {
+	if h.OnStart == nil {
+		return nil
+	}
+	return h.OnStart(ctx)
+}
+
+func (h Hooks) Stop(ctx context.Context) error {
+	if h.OnStop == nil {
+		return nil
+	}
+	return h.OnStop(ctx)
+}
+
+type component struct {
+	name    string
+	c       Component
+	opts    Options
+	started bool
+}
+
+// Manager starts components in dependency order and stops them in reverse.
+type Manager struct {
+	logger       log.Logger
+	startTimeout time.Duration
+
+	// run serializes Start and Stop. mu guards registered components and their state,
+	// it's not held while components start or stop.
+	run        sync.Mutex
+	mu         sync.Mutex
+	components []*component
+	byName     map[string]*component
+	order      []*component
+}
+
+// New creates Manager, startTimeout limits start of each component unless overridden in Options.
+func New(logger log.Logger, startTimeout time.Duration) *Manager {
+	return &Manager{
+		logger:       logger,
+		startTimeout: startTimeout,
+		byName:       make(map[string]*component),
+	}
+}
+
+// Add registers component, it must be called before Start.
+func (m *Manager) Add(name string, c Component, opts Options) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.byName[name]; ok {
+		panic(fmt.Sprintf("lifecycle: component %s is already registered", name))
+	}
+	entry := &component{name: name, c: c, opts: opts}
+	m.components = append(m.components, entry)
+	m.byName[name] = entry
+}
+
+// Start starts components in dependency order. If a fail-fast component fails,
+// already started components are stopped and the error is returned.
+func (m *Manager) Start(ctx context.Context) error {
+	m.run.Lock()
+	defer m.run.Unlock()
+
+	m.mu.Lock()
+	order, err := m.sort()
+	if err == nil {
+		m.order = order
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range order {
+		err := m.start(ctx, entry)
+		m.mu.Lock()
+		entry.started = err == nil
+		m.mu.Unlock()
+		if err == nil {
+			continue
+		}
+
+		if entry.opts.Policy == Degraded {
+			m.logger.WithError(err).Warnf("component %s failed to start, continue in degraded mode", entry.name)
+			continue
+		}
+
+		m.logger.WithError(err).Errorf("component %s failed to start", entry.name)
+		m.stop(ctx)
+		return fmt.Errorf("could not start %s: %w", entry.name, err)
+	}
+	return nil
+}
+
+func (m *Manager) start(ctx context.Context, entry *component) error {
+	for _, dep := range entry.opts.DependsOn {
+		m.mu.Lock()
+		started := m.byName[dep].started
+		m.mu.Unlock()
+		if !started {
+			return fmt.Errorf("dependency %s is not started", dep)
+		}
+	}
+
+	timeout := entry.opts.StartTimeout
+	if timeout == 0 {
+		timeout = m.startTimeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	m.logger.Debugf("starting component %s", entry.name)
+	started := time.Now()
+
+	// Start is not abandoned on timeout, otherwise it could complete later and leave the component
+	// running unnoticed while the rest of application is stopped.
+	err := entry.c.Start(ctx)
+	if err == nil && ctx.Err() != nil {
+		// Component started regardless, but the caller has already given up on it.
+		if stopErr := entry.c.Stop(context.Background()); stopErr != nil {
+			m.logger.WithError(stopErr).Errorf("could not stop component %s", entry.name)
+		}
+		err = ctx.Err()
+	}
+	if err != nil {
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			return fmt.Errorf("start timed out after %s: %w", timeout, err)
+		case ctx.Err() != nil:
+			return fmt.Errorf("start canceled: %w", err)
+		}
+		return err
+	}
+
+	m.logger.Infof("component %s started in %s", entry.name, time.Since(started).Round(time.Millisecond))
+	return nil
+}
+
+// Stop stops started components in reverse dependency order. All components are stopped
+// even if some of them fail, errors are joined.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.run.Lock()
+	defer m.run.Unlock()
+
+	return m.stop(ctx)
+}
+
+// stop must be called with run held.
+func (m *Manager) stop(ctx context.Context) error {
+	m.mu.Lock()
+	order := m.order
+	m.mu.Unlock()
+
+	var errs []string
+	for i := len(order) - 1; i >= 0; i-- {
+		entry := order[i]
+		m.mu.Lock()
+		started := entry.started
+		entry.started = false
+		m.mu.Unlock()
+		if !started {
+			continue
+		}
+
+		m.logger.Debugf("stopping component %s", entry.name)
+		if err := entry.c.Stop(ctx); err != nil {
+			m.logger.WithError(err).Errorf("could not stop component %s", entry.name)
+			errs = append(errs, fmt.Sprintf("%s: %s", entry.name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("could not stop components: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// sort orders components so dependencies go first, registration order is kept otherwise.
+func (m *Manager) sort() ([]*component, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(m.components))
+	order := make([]*component, 0, len(m.components))
+
+	var visit func(entry *component, path []string) error
+	visit = func(entry *component, path []string) error {
+		switch state[entry.name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, entry.name), " -> "))
+		}
+		state[entry.name] = visiting
+
+		for _, dep := range entry.opts.DependsOn {
+			depEntry, ok := m.byName[dep]
+			if !ok {
+				return fmt.Errorf("component %s depends on unknown component %s", entry.name, dep)
+			}
+			if err := visit(depEntry, append(path, entry.name)); err != nil {
+				return err
+			}
+		}
+
+		state[entry.name] = visited
+		order = append(order, entry)
+		return nil
+	}
+
+	for _, entry := range m.components {
+		if err := visit(entry, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
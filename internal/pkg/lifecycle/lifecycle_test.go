@@ -0,0 +1,144 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agalitsyn/go-app/internal/pkg/log"
+)
+
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) component(name string, startErr error) Hooks {
+	return Hooks{
+		OnStart: func(ctx context.Context) error {
+			r.events = append(r.events, "start "+name)
+			return startErr
+		},
+		OnStop: func(ctx context.Context) error {
+			r.events = append(r.events, "stop "+name)
+			return nil
+		},
+	}
+}
+
+func newTestManager() *Manager {
+	return New(log.New("text", "error", ioutil.Discard), time.Second)
+}
+
+func TestManager_order(t *testing.T) {
+	t.Parallel()
+
+	var rec recorder
+	m := newTestManager()
+	m.Add("http", rec.component("http", nil), Options{DependsOn: []string{"cache", "postgres"}})
+	m.Add("cache", rec.component("cache", nil), Options{DependsOn: []string{"postgres"}})
+	m.Add("postgres", rec.component("postgres", nil), Options{})
+
+	require.NoError(t, m.Start(context.Background()))
+	require.NoError(t, m.Stop(context.Background()))
+
+	assert.Equal(t, []string{
+		"start postgres", "start cache", "start http",
+		"stop http", "stop cache", "stop postgres",
+	}, rec.events)
+}
+
+func TestManager_failFast(t *testing.T) {
+	t.Parallel()
+
+	var rec recorder
+	m := newTestManager()
+	m.Add("postgres", rec.component("postgres", nil), Options{})
+	m.Add("cache", rec.component("cache", errors.New("boom")), Options{DependsOn: []string{"postgres"}})
+	m.Add("http", rec.component("http", nil), Options{DependsOn: []string{"cache"}})
+
+	err := m.Start(context.Background())
+	assert.EqualError(t, err, "could not start cache: boom")
+	assert.Equal(t, []string{"start postgres", "start cache", "stop postgres"}, rec.events)
+}
+
+func TestManager_degraded(t *testing.T) {
+	t.Parallel()
+
+	var rec recorder
+	m := newTestManager()
+	m.Add("postgres", rec.component("postgres", errors.New("boom")), Options{Policy: Degraded})
+	m.Add("schema", rec.component("schema", nil), Options{DependsOn: []string{"postgres"}, Policy: Degraded})
+	m.Add("http", rec.component("http", nil), Options{})
+
+	require.NoError(t, m.Start(context.Background()))
+	assert.Equal(t, []string{"start postgres", "start http"}, rec.events)
+
+	require.NoError(t, m.Stop(context.Background()))
+	assert.Equal(t, []string{"start postgres", "start http", "stop http"}, rec.events, "failed components are not stopped")
+}
+
+func TestManager_startTimeout(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		start   func(ctx context.Context) error
+		stopped bool
+	}{
+		{
+			name: "honours context",
+			start: func(ctx context.Context) error {
+				<-ctx.Done()
+				return fmt.Errorf("could not connect: %w", ctx.Err())
+			},
+		},
+		{
+			name: "started after deadline",
+			start: func(ctx context.Context) error {
+				<-ctx.Done()
+				return nil
+			},
+			stopped: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var stopped bool
+			m := newTestManager()
+			m.Add("slow", Hooks{
+				OnStart: tt.start,
+				OnStop: func(ctx context.Context) error {
+					stopped = true
+					return nil
+				},
+			}, Options{StartTimeout: 10 * time.Millisecond})
+
+			err := m.Start(context.Background())
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			assert.Contains(t, err.Error(), "start timed out after 10ms")
+			assert.Equal(t, tt.stopped, stopped, "component started too late is stopped")
+		})
+	}
+}
+
+func TestManager_invalidDependencies(t *testing.T) {
+	t.Parallel()
+
+	m := newTestManager()
+	m.Add("a", Hooks{}, Options{DependsOn: []string{"b"}})
+	m.Add("b", Hooks{}, Options{DependsOn: []string{"a"}})
+	assert.EqualError(t, m.Start(context.Background()), "dependency cycle: a -> b -> a")
+
+	m = newTestManager()
+	m.Add("a", Hooks{}, Options{DependsOn: []string{"c"}})
+	assert.Error(t, m.Start(context.Background()))
+}
@@ -28,20 +28,16 @@ func New(format, level string, output io.Writer) *StructuredLogger {
 
 	logger.Out = output
 
-	f := strings.ToLower(format)
-	switch f {
-	case "json":
-		logger.Formatter = &logrus.JSONFormatter{}
-	case "text":
-		logger.Formatter = &logrus.TextFormatter{ForceColors: true}
-	default:
-		logger.Warnf("log: invalid formatter: %v, continue with default", f)
+	f, err := parseFormat(format)
+	if err != nil {
+		logger.Warnf("log: %s, continue with default", err)
+	} else {
+		logger.Formatter = f
 	}
 
-	l := strings.ToLower(level)
-	sev, err := logrus.ParseLevel(l)
+	sev, err := logrus.ParseLevel(strings.ToLower(level))
 	if err != nil {
-		logger.Warnf("log: invalid level: %v, continue with info", l)
+		logger.Warnf("log: invalid level: %v, continue with info", strings.ToLower(level))
 		sev = logrus.InfoLevel
 	}
 	logger.Level = sev
@@ -49,6 +45,34 @@ func New(format, level string, output io.Writer) *StructuredLogger {
 	return &StructuredLogger{logger}
 }
 
+// Configure changes format and level of the logger, it's safe to call while logger is in use.
+func (l *StructuredLogger) Configure(format, level string) error {
+	f, err := parseFormat(format)
+	if err != nil {
+		return err
+	}
+	sev, err := logrus.ParseLevel(strings.ToLower(level))
+	if err != nil {
+		return fmt.Errorf("invalid level: %w", err)
+	}
+
+	l.SetFormatter(f)
+	l.SetLevel(sev)
+	return nil
+}
+
+func parseFormat(format string) (logrus.Formatter, error) {
+	f := strings.ToLower(format)
+	switch f {
+	case "json":
+		return &logrus.JSONFormatter{}, nil
+	case "text":
+		return &logrus.TextFormatter{ForceColors: true}, nil
+	default:
+		return nil, fmt.Errorf("invalid formatter: %v", f)
+	}
+}
+
 func (l *StructuredLogger) NewLogEntry(r *http.Request) middleware.LogEntry {
 	entry := &StructuredLoggerEntry{Logger: logrus.NewEntry(l.Logger)}
 	logFields := logrus.Fields{}
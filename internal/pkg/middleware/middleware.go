@@ -11,7 +11,10 @@ import (
 
 type contextKey string
 
-const APIVersionContextKey contextKey = "api.version"
+const (
+	APIVersionContextKey   contextKey = "api.version"
+	ListEnvelopeContextKey contextKey = "api.list_envelope"
+)
 
 func APIVersion(version string) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -22,6 +25,16 @@ func APIVersion(version string) func(next http.Handler) http.Handler {
 	}
 }
 
+// ListEnvelope enables wrapping of list responses with pagination metadata.
+func ListEnvelope() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = r.WithContext(context.WithValue(r.Context(), ListEnvelopeContextKey, true))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func RequestLogger(logger log.Logger) func(next http.Handler) http.Handler {
 	l, ok := logger.(middleware.LogFormatter)
 	if !ok {
@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const resetTimeout = time.Second
+
+// deadlineTimeouts sets statement_timeout of acquired connection to the time left until ctx deadline,
+// so server aborts queries which client won't wait for, and restores it on release.
+// Transactions set it locally instead, see setLocal.
+type deadlineTimeouts struct {
+	mu sync.Mutex
+	// session is statement_timeout connections start with, zero means no timeout.
+	// It's read from the first connection, deadline never loosens it.
+	session time.Duration
+	loaded  bool
+	// changed contains PIDs of acquired connections with changed timeout.
+	changed map[uint32]struct{}
+}
+
+func newDeadlineTimeouts() *deadlineTimeouts {
+	return &deadlineTimeouts{changed: make(map[uint32]struct{})}
+}
+
+// quietKey marks internal queries which are not logged.
+type quietKey struct{}
+
+// localTimeoutKey marks acquire of connection for transaction, which sets the timeout on its own.
+type localTimeoutKey struct{}
+
+// afterConnect reads session default of statement_timeout, which includes server config, role settings
+// and connection parameters. All connections of pool share them, so it's read once.
+func (d *deadlineTimeouts) afterConnect(ctx context.Context, conn *pgx.Conn) error {
+	d.mu.Lock()
+	loaded := d.loaded
+	d.mu.Unlock()
+	if loaded {
+		return nil
+	}
+
+	var ms int64
+	err := conn.QueryRow(context.WithValue(ctx, quietKey{}, true),
+		"SELECT setting::bigint FROM pg_settings WHERE name = 'statement_timeout'").Scan(&ms)
+	if err != nil {
+		return fmt.Errorf("could not get statement timeout: %w", err)
+	}
+
+	d.mu.Lock()
+	d.session = time.Duration(ms) * time.Millisecond
+	d.loaded = true
+	d.mu.Unlock()
+	return nil
+}
+
+// timeout returns statement_timeout value for ctx deadline, false means session default is stricter.
+func (d *deadlineTimeouts) timeout(ctx context.Context) (string, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return "", false
+	}
+	timeout := time.Until(deadline)
+	if timeout <= 0 {
+		return "", false
+	}
+	d.mu.Lock()
+	session := d.session
+	d.mu.Unlock()
+	if session > 0 && session <= timeout {
+		return "", false
+	}
+
+	// Rounded up, because zero disables timeout.
+	ms := (timeout + time.Millisecond - 1) / time.Millisecond
+	return strconv.FormatInt(int64(ms), 10), true
+}
+
+func (d *deadlineTimeouts) beforeAcquire(ctx context.Context, conn *pgx.Conn) bool {
+	if ctx.Value(localTimeoutKey{}) != nil {
+		return true
+	}
+	timeout, ok := d.timeout(ctx)
+	if !ok {
+		return true
+	}
+
+	_, err := conn.Exec(context.WithValue(ctx, quietKey{}, true), "SELECT set_config('statement_timeout', $1, false)", timeout)
+	if err != nil {
+		return !conn.IsClosed()
+	}
+
+	d.mu.Lock()
+	d.changed[conn.PgConn().PID()] = struct{}{}
+	d.mu.Unlock()
+	return true
+}
+
+func (d *deadlineTimeouts) afterRelease(conn *pgx.Conn) bool {
+	pid := conn.PgConn().PID()
+	d.mu.Lock()
+	_, ok := d.changed[pid]
+	delete(d.changed, pid)
+	d.mu.Unlock()
+	if !ok {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), quietKey{}, true), resetTimeout)
+	defer cancel()
+	// Session default includes statement_timeout of connection parameters.
+	_, err := conn.Exec(ctx, "RESET statement_timeout")
+	return err == nil
+}
+
+// setLocal sets statement_timeout for the rest of transaction, it's restored on commit or rollback,
+// so connection doesn't need reset on release.
+func (d *deadlineTimeouts) setLocal(ctx context.Context, tx pgx.Tx) error {
+	timeout, ok := d.timeout(ctx)
+	if !ok {
+		return nil
+	}
+	_, err := tx.Exec(context.WithValue(ctx, quietKey{}, true), "SELECT set_config('statement_timeout', $1, true)", timeout)
+	if err != nil {
+		return fmt.Errorf("could not set statement timeout: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadlineTimeouts_timeout(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		session  time.Duration
+		deadline time.Duration
+		want     bool
+	}{
+		{name: "no deadline", session: time.Minute},
+		{name: "expired", session: time.Minute, deadline: -time.Second},
+		{name: "shorter than session", session: time.Minute, deadline: 10 * time.Second, want: true},
+		{name: "longer than session", session: time.Second, deadline: 10 * time.Second},
+		{name: "no session timeout", deadline: 10 * time.Second, want: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			d := newDeadlineTimeouts()
+			d.session = tt.session
+			ctx := context.Background()
+			if tt.deadline != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+
+			timeout, ok := d.timeout(ctx)
+			assert.Equal(t, tt.want, ok)
+			if tt.want {
+				ms, err := strconv.ParseInt(timeout, 10, 64)
+				require.NoError(t, err)
+				assert.True(t, ms > 9000 && ms <= 10000, "time left in milliseconds: %s", timeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Listen calls fn with payload of every notification sent to channel with NOTIFY until ctx is canceled.
+// It holds a dedicated connection outside of pool, which is reestablished with backoff if lost. Notifications sent while
+// disconnected are missed, so fn is called with empty payload after every connect, including the first one.
+func (d *DB) Listen(ctx context.Context, channel string, fn func(payload string)) {
+	for attempt := 1; ; attempt++ {
+		err := d.listen(ctx, channel, func() { attempt = 0 }, fn)
+		if ctx.Err() != nil {
+			return
+		}
+
+		wait := connectBackoff(attempt)
+		d.Logger.WithError(err).Warnf("could not listen to %s, retry in %v", channel, wait)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (d *DB) listen(ctx context.Context, channel string, connected func(), fn func(payload string)) error {
+	connCfg, err := d.listenConfig()
+	if err != nil {
+		return err
+	}
+	// Connection is not taken from pool, so it doesn't reduce connections available for queries.
+	conn, err := pgx.ConnectConfig(ctx, connCfg)
+	if err != nil {
+		return fmt.Errorf("could not connect: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return fmt.Errorf("could not subscribe: %w", err)
+	}
+	connected()
+	fn("")
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("could not wait for notification: %w", err)
+		}
+		fn(n.Payload)
+	}
+}
+
+// listenConfig returns config of primary, DB created without New, e.g. in tests, takes it from the pool.
+func (d *DB) listenConfig() (*pgx.ConnConfig, error) {
+	switch {
+	case d.cfg != nil:
+		return d.cfg.ConnConfig.Copy(), nil
+	case d.Session != nil:
+		return d.Session.Config().ConnConfig.Copy(), nil
+	default:
+		return nil, errors.New("not configured")
+	}
+}
@@ -0,0 +1,182 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jackc/tern/migrate"
+)
+
+// MigrationsVersionTable is the same table which tern CLI uses by default,
+// so databases migrated with tern binary keep their version.
+const MigrationsVersionTable = "schema_version"
+
+// migrationsLockID is the advisory lock number used by tern. Session level advisory locks are reentrant,
+// so holding it for the whole run doesn't block tern and prevents replicas from racing each other.
+const migrationsLockID = int64(9628173550095224)
+
+const undefinedTableCode = "42P01"
+
+// Migrator applies tern migrations loaded from fs, e.g. from embedded migrations folder.
+type Migrator struct {
+	db         *DB
+	migrations fs.FS
+}
+
+func NewMigrator(db *DB, migrations fs.FS) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+type MigrationStatus struct {
+	Current int32
+	Latest  int32
+	// Pending contains names of migrations which are not applied yet.
+	Pending []string
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.run(ctx, func(mg *migrate.Migrator) error {
+		return mg.Migrate(ctx)
+	})
+}
+
+// Down rollbacks the last applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.run(ctx, func(mg *migrate.Migrator) error {
+		current, err := mg.GetCurrentVersion(ctx)
+		if err != nil {
+			return fmt.Errorf("could not get current version: %w", err)
+		}
+		if current == 0 {
+			return errors.New("no migrations to rollback")
+		}
+		return mg.MigrateTo(ctx, current-1)
+	})
+}
+
+// To migrates up or down to the version.
+func (m *Migrator) To(ctx context.Context, version int32) error {
+	return m.run(ctx, func(mg *migrate.Migrator) error {
+		return mg.MigrateTo(ctx, version)
+	})
+}
+
+func (m *Migrator) Status(ctx context.Context) (MigrationStatus, error) {
+	var status MigrationStatus
+	err := m.run(ctx, func(mg *migrate.Migrator) error {
+		current, err := mg.GetCurrentVersion(ctx)
+		if err != nil {
+			return fmt.Errorf("could not get current version: %w", err)
+		}
+
+		status.Current = current
+		status.Latest = int32(len(mg.Migrations))
+		for _, migration := range mg.Migrations {
+			if migration.Sequence > current {
+				status.Pending = append(status.Pending, migration.Name)
+			}
+		}
+		return nil
+	})
+	return status, err
+}
+
+// ExpectedVersion returns version of the latest migration known to the binary.
+func (m *Migrator) ExpectedVersion() (int32, error) {
+	paths, err := migrate.FindMigrationsEx(".", migratorFS{fsys: m.migrations})
+	if err != nil {
+		return 0, fmt.Errorf("could not find migrations: %w", err)
+	}
+	return int32(len(paths)), nil
+}
+
+// AppliedVersion returns version of the database schema. Unlike Status it doesn't take the migrations lock
+// and doesn't create version table, so it's safe to call on a database which was never migrated.
+func (m *Migrator) AppliedVersion(ctx context.Context) (int32, error) {
+	var version int32
+	err := m.db.Session.QueryRow(ctx, "SELECT version FROM "+MigrationsVersionTable).Scan(&version)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == undefinedTableCode {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("could not get schema version: %w", err)
+	}
+	return version, nil
+}
+
+func (m *Migrator) run(ctx context.Context, fn func(mg *migrate.Migrator) error) error {
+	conn, err := m.db.Session.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("could not acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if err = lockMigrations(ctx, conn); err != nil {
+		return err
+	}
+	defer unlockMigrations(conn)
+
+	mg, err := migrate.NewMigratorEx(ctx, conn.Conn(), MigrationsVersionTable, &migrate.MigratorOptions{
+		MigratorFS: migratorFS{fsys: m.migrations},
+	})
+	if err != nil {
+		return fmt.Errorf("could not init migrator: %w", err)
+	}
+	if err = mg.LoadMigrations("."); err != nil {
+		return fmt.Errorf("could not load migrations: %w", err)
+	}
+	mg.OnStart = func(sequence int32, name, direction, _ string) {
+		m.db.Logger.Infof("migrating %s %d: %s", direction, sequence, name)
+	}
+
+	return fn(mg)
+}
+
+func lockMigrations(ctx context.Context, conn *pgxpool.Conn) error {
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationsLockID); err != nil {
+		return fmt.Errorf("could not acquire migrations lock: %w", err)
+	}
+	return nil
+}
+
+func unlockMigrations(conn *pgxpool.Conn) {
+	// Unlock even if the migration context is already canceled, otherwise the pooled connection keeps the lock.
+	conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationsLockID)
+}
+
+// migratorFS adapts fs.FS to tern, which doesn't support io/fs yet.
+type migratorFS struct {
+	fsys fs.FS
+}
+
+func (m migratorFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	entries, err := fs.ReadDir(m.fsys, dirname)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (m migratorFS) ReadFile(filename string) ([]byte, error) {
+	return fs.ReadFile(m.fsys, filename)
+}
+
+func (m migratorFS) Glob(pattern string) ([]string, error) {
+	return fs.Glob(m.fsys, pattern)
+}
@@ -0,0 +1,19 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/jackc/tern/migrate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agalitsyn/go-app/migrations"
+)
+
+func TestMigratorFS(t *testing.T) {
+	paths, err := migrate.FindMigrationsEx(".", migratorFS{fsys: migrations.FS})
+	require.NoError(t, err)
+
+	require.NotEmpty(t, paths)
+	assert.Equal(t, "001_init.sql", paths[0])
+}
@@ -0,0 +1,194 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/agalitsyn/go-app/internal/pkg/health"
+)
+
+// MonitorReadinessReason is the reason of unreadiness set by Monitor.
+const MonitorReadinessReason = "postgres"
+
+type MonitorOptions struct {
+	// Interval between pings.
+	Interval time.Duration
+	// Timeout of a single ping, Interval by default.
+	Timeout time.Duration
+	// FailureThreshold is number of consecutive failed pings after which database is considered down.
+	FailureThreshold int
+}
+
+// Monitor pings database in background and marks service unready while database is down,
+// replicas are checked as well, see DB.CheckReplicas.
+type Monitor struct {
+	db        *DB
+	opts      MonitorOptions
+	heartbeat *health.Heartbeat
+	ping      func(ctx context.Context) error
+
+	mu       sync.RWMutex
+	failures int
+	down     bool
+	lastErr  error
+}
+
+func NewMonitor(db *DB, opts MonitorOptions) *Monitor {
+	if opts.Timeout == 0 {
+		opts.Timeout = opts.Interval
+	}
+	if opts.FailureThreshold < 1 {
+		opts.FailureThreshold = 1
+	}
+
+	m := &Monitor{
+		db:        db,
+		opts:      opts,
+		heartbeat: health.NewHeartbeat(opts.Interval),
+	}
+	m.ping = func(ctx context.Context) error {
+		if db.Session == nil {
+			return errors.New("not connected")
+		}
+		return db.Session.Ping(ctx)
+	}
+	return m
+}
+
+// Run pings database every interval until ctx is done.
+func (m *Monitor) Run(ctx context.Context) {
+	m.heartbeat.Start()
+	defer m.heartbeat.Stop()
+
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.heartbeat.Beat()
+		m.check(ctx)
+		m.checkReplicas(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Monitor) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, m.opts.Timeout)
+	err := m.ping(ctx)
+	cancel()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err == nil {
+		m.failures = 0
+		m.lastErr = nil
+		if m.down {
+			m.down = false
+			m.db.Logger.Info("postgres is available again, service is ready")
+			health.SetUnready(MonitorReadinessReason, nil)
+		}
+		return
+	}
+
+	m.failures++
+	m.lastErr = err
+	if !m.down && m.failures >= m.opts.FailureThreshold {
+		m.down = true
+		m.db.Logger.WithError(err).Errorf("postgres is unavailable after %d failed pings, service is unready", m.failures)
+		health.SetUnready(MonitorReadinessReason, err)
+	}
+}
+
+func (m *Monitor) checkReplicas(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, m.opts.Timeout)
+	defer cancel()
+	m.db.CheckReplicas(ctx)
+}
+
+// Check is health check failing while database is considered down.
+func (m *Monitor) Check(ctx context.Context) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.down {
+		return fmt.Errorf("%d failed pings: %w", m.failures, m.lastErr)
+	}
+	return nil
+}
+
+// Heartbeat is beaten on every ping, it allows to detect stuck monitor.
+func (m *Monitor) Heartbeat() *health.Heartbeat {
+	return m.heartbeat
+}
+
+// PoolStats is a snapshot of pool statistics.
+type PoolStats struct {
+	AcquiredConns int32 `json:"acquired_conns"`
+	IdleConns     int32 `json:"idle_conns"`
+	TotalConns    int32 `json:"total_conns"`
+	MaxConns      int32 `json:"max_conns"`
+	// AcquireCount is number of successful acquires, EmptyAcquireCount of them had to wait for a connection.
+	AcquireCount         int64 `json:"acquire_count"`
+	EmptyAcquireCount    int64 `json:"empty_acquire_count"`
+	CanceledAcquireCount int64 `json:"canceled_acquire_count"`
+	// AcquireDurationMs is total time spent waiting for connections.
+	AcquireDurationMs float64 `json:"acquire_duration_ms"`
+	// AvgAcquireDurationMs is average time of an acquire.
+	AvgAcquireDurationMs float64 `json:"avg_acquire_duration_ms"`
+}
+
+// Stats returns pool statistics, they are zero if pool was not created.
+func (d *DB) Stats() PoolStats {
+	if d.Session == nil {
+		return PoolStats{}
+	}
+
+	stat := d.Session.Stat()
+	stats := PoolStats{
+		AcquiredConns:        stat.AcquiredConns(),
+		IdleConns:            stat.IdleConns(),
+		TotalConns:           stat.TotalConns(),
+		MaxConns:             stat.MaxConns(),
+		AcquireCount:         stat.AcquireCount(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
+		AcquireDurationMs:    float64(stat.AcquireDuration().Microseconds()) / 1000,
+	}
+	if stats.AcquireCount > 0 {
+		stats.AvgAcquireDurationMs = stats.AcquireDurationMs / float64(stats.AcquireCount)
+	}
+	return stats
+}
+
+// AcquireWait returns function which reports average time of waiting for a connection since its previous call.
+func (d *DB) AcquireWait() func() time.Duration {
+	var (
+		mu        sync.Mutex
+		prevCount int64
+		prevTotal time.Duration
+	)
+	return func() time.Duration {
+		if d.Session == nil {
+			return 0
+		}
+		stat := d.Session.Stat()
+
+		mu.Lock()
+		defer mu.Unlock()
+		count, total := stat.AcquireCount(), stat.AcquireDuration()
+		n, wait := count-prevCount, total-prevTotal
+		prevCount, prevTotal = count, total
+		if n <= 0 {
+			return 0
+		}
+		return wait / time.Duration(n)
+	}
+}
@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/agalitsyn/go-app/internal/pkg/health"
+	"github.com/agalitsyn/go-app/internal/pkg/log"
+)
+
+func TestMonitor(t *testing.T) {
+	db := &DB{Logger: log.New("text", "error", ioutil.Discard)}
+	m := NewMonitor(db, MonitorOptions{Interval: time.Second, FailureThreshold: 2})
+
+	var pingErr error
+	m.ping = func(ctx context.Context) error { return pingErr }
+	ctx := context.Background()
+
+	m.check(ctx)
+	assert.NoError(t, m.Check(ctx))
+
+	pingErr = errors.New("connection refused")
+	m.check(ctx)
+	assert.NoError(t, m.Check(ctx), "single failure is tolerated")
+	assert.Equal(t, http.StatusOK, health.ReadinessStatus())
+
+	m.check(ctx)
+	assert.Error(t, m.Check(ctx))
+	assert.Equal(t, http.StatusServiceUnavailable, health.ReadinessStatus())
+
+	pingErr = nil
+	m.check(ctx)
+	assert.NoError(t, m.Check(ctx))
+	assert.Equal(t, http.StatusOK, health.ReadinessStatus())
+}
@@ -3,51 +3,204 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"math/rand"
+	"strconv"
 	"time"
 
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 
 	"github.com/agalitsyn/go-app/internal/pkg/log"
 )
 
+const (
+	connectBackoffMin = 500 * time.Millisecond
+	connectBackoffMax = 30 * time.Second
+)
+
+// Options override pool settings, zero values keep settings from connection string or pgx defaults.
+type Options struct {
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+	ConnectTimeout    time.Duration
+	// StatementTimeout is set as statement_timeout session parameter. Queries with ctx deadline
+	// get statement_timeout of the time left if it's shorter than session one.
+	StatementTimeout time.Duration
+	// ConnectAttempts limits attempts of Connect, zero means retry until ctx is done.
+	ConnectAttempts int
+	// ReplicaURLs are connection strings of read replicas, see Read.
+	ReplicaURLs []string
+	// Tx are default options of transactions, see DB.Tx.
+	Tx TxOptions
+	// QueryLog enables logging of queries.
+	QueryLog *QueryLogOptions
+}
+
 type DB struct {
-	cfg *pgxpool.Config
+	cfg             *pgxpool.Config
+	connectAttempts int
+	txOpts          TxOptions
+	timeouts        *deadlineTimeouts
+	replicas        []*replica
+	// next is round-robin counter of replicas.
+	next uint32
 
 	Session *pgxpool.Pool
 	Logger  log.Logger
 }
 
-func New(connString string, logger log.Logger) (*DB, error) {
+func New(connString string, opts Options, logger log.Logger) (*DB, error) {
 	connCfg, err := pgxpool.ParseConfig(connString)
 	if err != nil {
 		return nil, fmt.Errorf("connection string parse error: %w", err)
 	}
 
+	opts.apply(connCfg)
+	timeouts := newDeadlineTimeouts()
+	connCfg.AfterConnect = timeouts.afterConnect
+	connCfg.BeforeAcquire = timeouts.beforeAcquire
+	connCfg.AfterRelease = timeouts.afterRelease
+
 	// If you use pgpool, you need to use simple protocol
 	//connCfg.ConnConfig.PreferSimpleProtocol = true
 	//connCfg.ConnConfig.BuildStatementCache = func(conn *pgconn.PgConn) stmtcache.Cache {
 	//	return stmtcache.New(conn, stmtcache.ModeDescribe, 512)
 	//}
 
-	return &DB{cfg: connCfg, Logger: logger}, nil
+	db := &DB{cfg: connCfg, connectAttempts: opts.ConnectAttempts, txOpts: opts.Tx, timeouts: timeouts, Logger: logger}
+	var queryLog *queryLogger
+	if opts.QueryLog != nil {
+		queryLog = &queryLogger{logger: logger, opts: *opts.QueryLog}
+		connCfg.ConnConfig.Logger = queryLog
+		connCfg.ConnConfig.LogLevel = pgx.LogLevelInfo
+	}
+
+	for i, replicaURL := range opts.ReplicaURLs {
+		replicaCfg, err := pgxpool.ParseConfig(replicaURL)
+		if err != nil {
+			return nil, fmt.Errorf("replica %d connection string parse error: %w", i+1, err)
+		}
+		opts.apply(replicaCfg)
+		// PIDs are unique only within server, so each pool tracks own connections.
+		replicaTimeouts := newDeadlineTimeouts()
+		replicaCfg.AfterConnect = replicaTimeouts.afterConnect
+		replicaCfg.BeforeAcquire = replicaTimeouts.beforeAcquire
+		replicaCfg.AfterRelease = replicaTimeouts.afterRelease
+		replicaCfg.ConnConfig.Logger = connCfg.ConnConfig.Logger
+		replicaCfg.ConnConfig.LogLevel = connCfg.ConnConfig.LogLevel
+		db.replicas = append(db.replicas, &replica{
+			name: fmt.Sprintf("%s:%d", replicaCfg.ConnConfig.Host, replicaCfg.ConnConfig.Port),
+			cfg:  replicaCfg,
+		})
+	}
+	if queryLog != nil {
+		// Plans are taken from primary, replicas are expected to have the same data.
+		queryLog.explain = db.explainAnalyze
+	}
+	return db, nil
+}
+
+func (o Options) apply(cfg *pgxpool.Config) {
+	if o.MaxConns > 0 {
+		cfg.MaxConns = o.MaxConns
+	}
+	if o.MinConns > 0 {
+		cfg.MinConns = o.MinConns
+	}
+	if o.MaxConnLifetime > 0 {
+		cfg.MaxConnLifetime = o.MaxConnLifetime
+	}
+	if o.MaxConnIdleTime > 0 {
+		cfg.MaxConnIdleTime = o.MaxConnIdleTime
+	}
+	if o.HealthCheckPeriod > 0 {
+		cfg.HealthCheckPeriod = o.HealthCheckPeriod
+	}
+	if o.ConnectTimeout > 0 {
+		cfg.ConnConfig.ConnectTimeout = o.ConnectTimeout
+	}
+	if o.StatementTimeout > 0 {
+		cfg.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(o.StatementTimeout.Milliseconds(), 10)
+	}
 }
 
+// Connect creates pool and establishes connections, failed attempts are retried with exponential backoff
+// until ConnectAttempts are exhausted or ctx is done.
 func (d *DB) Connect(ctx context.Context) error {
 	var err error
-	maxAttempts := 10
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
+	for attempt := 1; d.connectAttempts == 0 || attempt <= d.connectAttempts; attempt++ {
 		var pool *pgxpool.Pool
 		pool, err = pgxpool.ConnectConfig(ctx, d.cfg)
 		if err == nil {
 			d.Session = pool
+			if err = d.connectReplicas(); err != nil {
+				return err
+			}
+			d.CheckReplicas(ctx)
+			return nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if attempt == d.connectAttempts {
 			break
 		}
-		nextAttemptWait := time.Duration(attempt) * time.Second
-		d.Logger.Warnf("attempt %v: could not establish a connection with the database, wait for %v.", attempt, nextAttemptWait)
-		time.Sleep(nextAttemptWait)
+
+		wait := connectBackoff(attempt)
+		d.Logger.Warnf("attempt %v: could not establish a connection with the database, wait for %v: %s", attempt, wait, err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("could not connect to database: %w", ctx.Err())
+		case <-timer.C:
+		}
 	}
+	return fmt.Errorf("could not connect to database: %w", err)
+}
+
+// connectBackoff doubles wait time with every attempt, a random half of it is jitter,
+// so replicas which lost database at the same time don't reconnect simultaneously.
+func connectBackoff(attempt int) time.Duration {
+	d := connectBackoffMax
+	if attempt < 16 {
+		if exp := connectBackoffMin << uint(attempt-1); exp < connectBackoffMax {
+			d = exp
+		}
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Tx returns transaction manager with options of DB.
+func (d *DB) Tx() *TxManager {
+	return NewTxManager(d, d.txOpts)
+}
+
+// ConnectLazy creates pool without establishing connections, they are opened on first use.
+// It allows to serve requests which don't need database while it's unavailable.
+func (d *DB) ConnectLazy() error {
+	cfg := d.cfg.Copy()
+	cfg.LazyConnect = true
+
+	pool, err := pgxpool.ConnectConfig(context.Background(), cfg)
 	if err != nil {
-		return fmt.Errorf("could not connect to database: %w", err)
+		return fmt.Errorf("could not create pool: %w", err)
+	}
+	d.Session = pool
+	return d.connectReplicas()
+}
+
+// Close closes all connections, it's safe to call if pool was not created.
+func (d *DB) Close() {
+	if d.Session != nil {
+		d.Session.Close()
+	}
+	for _, r := range d.replicas {
+		if r.pool != nil {
+			r.pool.Close()
+		}
 	}
-	return nil
 }
@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agalitsyn/go-app/internal/pkg/log"
+)
+
+func TestNew_options(t *testing.T) {
+	t.Parallel()
+
+	db, err := New("postgres://postgres@127.0.0.1/postgres?pool_max_conns=7", Options{
+		MinConns:         2,
+		ConnectTimeout:   3 * time.Second,
+		StatementTimeout: 1500 * time.Millisecond,
+	}, log.New("text", "error", ioutil.Discard))
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(7), db.cfg.MaxConns, "kept from connection string")
+	assert.Equal(t, int32(2), db.cfg.MinConns)
+	assert.Equal(t, 3*time.Second, db.cfg.ConnConfig.ConnectTimeout)
+	assert.Equal(t, "1500", db.cfg.ConnConfig.RuntimeParams["statement_timeout"])
+}
+
+func TestConnectBackoff(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{attempt: 1, min: 250 * time.Millisecond, max: 500 * time.Millisecond},
+		{attempt: 3, min: time.Second, max: 2 * time.Second},
+		{attempt: 10, min: 15 * time.Second, max: 30 * time.Second},
+		{attempt: 100, min: 15 * time.Second, max: 30 * time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 10; i++ {
+			d := connectBackoff(tt.attempt)
+			assert.True(t, d >= tt.min && d <= tt.max, "attempt %d: %s", tt.attempt, d)
+		}
+	}
+}
+
+func TestDB_Connect_canceled(t *testing.T) {
+	t.Parallel()
+
+	// Nothing listens on port 1, so connection is refused immediately and Connect waits for backoff.
+	db, err := New("postgres://postgres@127.0.0.1:1/postgres", Options{}, log.New("text", "error", ioutil.Discard))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = db.Connect(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
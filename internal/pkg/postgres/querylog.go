@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/sirupsen/logrus"
+
+	"github.com/agalitsyn/go-app/internal/pkg/log"
+)
+
+const explainTimeout = 10 * time.Second
+
+type QueryLogOptions struct {
+	// SlowThreshold is duration after which query is logged with warning, zero disables it.
+	SlowThreshold time.Duration
+	// Args are logged as is, otherwise only their types are logged, because they may contain personal data.
+	Args bool
+	// Explain logs plan of slow SELECT queries if debug level is enabled. Queries are executed again
+	// with EXPLAIN ANALYZE in background, with arguments as logged by pgx, e.g. long strings are truncated.
+	Explain bool
+}
+
+// queryLogger adapts pgx logging: all queries are logged with debug level, slow and failed ones with warning and error.
+type queryLogger struct {
+	logger log.Logger
+	opts   QueryLogOptions
+	// explain runs EXPLAIN ANALYZE, it's set when pool is created.
+	explain func(ctx context.Context, sql string, args []interface{}) (string, error)
+}
+
+type explainKey struct{}
+
+func (l *queryLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	switch msg {
+	case "Query", "Exec", "CopyFrom", "SendBatch":
+	default:
+		return
+	}
+	if ctx.Value(quietKey{}) != nil {
+		return
+	}
+
+	fields := logrus.Fields{"query": msg}
+	if reqID := middleware.GetReqID(ctx); reqID != "" {
+		fields["req_id"] = reqID
+	}
+	sql, _ := data["sql"].(string)
+	if sql != "" {
+		fields["sql"] = compactSQL(sql)
+	}
+	args, _ := data["args"].([]interface{})
+	if len(args) > 0 {
+		fields["args"] = l.logArgs(args)
+	}
+	duration, _ := data["time"].(time.Duration)
+	if duration > 0 {
+		fields["duration_ms"] = float64(duration.Microseconds()) / 1000
+	}
+	if rows, ok := data["rowCount"]; ok {
+		fields["rows"] = rows
+	}
+	if tag, ok := data["commandTag"].(pgconn.CommandTag); ok {
+		fields["rows"] = tag.RowsAffected()
+	}
+
+	entry := l.logger.WithFields(fields)
+	if level == pgx.LogLevelError {
+		entry.WithField("error", data["err"]).Error("query failed")
+		return
+	}
+	if l.opts.SlowThreshold == 0 || duration < l.opts.SlowThreshold {
+		entry.Debug("query")
+		return
+	}
+
+	entry.Warn("slow query")
+
+	// Plan is logged separately, so the query which holds connection is not delayed.
+	if l.opts.Explain && l.explain != nil && isSelect(sql) && ctx.Value(explainKey{}) == nil && debugEnabled(l.logger) {
+		go func() {
+			plan, err := l.explain(context.WithValue(context.Background(), explainKey{}, true), sql, args)
+			if err != nil {
+				entry.WithError(err).Debug("could not explain slow query")
+				return
+			}
+			entry.WithField("plan", plan).Debug("slow query plan")
+		}()
+	}
+}
+
+func (l *queryLogger) logArgs(args []interface{}) []interface{} {
+	if l.opts.Args {
+		return args
+	}
+	redacted := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		redacted = append(redacted, fmt.Sprintf("<%T>", arg))
+	}
+	return redacted
+}
+
+// explainAnalyze runs query with EXPLAIN ANALYZE and returns plan as text.
+func (d *DB) explainAnalyze(ctx context.Context, sql string, args []interface{}) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, explainTimeout)
+	defer cancel()
+
+	rows, err := d.Session.Query(ctx, "EXPLAIN ANALYZE "+sql, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err = rows.Scan(&line); err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	if err = rows.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func debugEnabled(logger log.Logger) bool {
+	l, ok := logger.(interface{ IsLevelEnabled(logrus.Level) bool })
+	return ok && l.IsLevelEnabled(logrus.DebugLevel)
+}
+
+// isSelect reports if query is read only, so it's safe to execute it again.
+func isSelect(sql string) bool {
+	fields := strings.Fields(sql)
+	return len(fields) > 0 && strings.EqualFold(fields[0], "SELECT")
+}
+
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryLogger(t *testing.T) {
+	t.Parallel()
+
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	l := &queryLogger{logger: logger, opts: QueryLogOptions{SlowThreshold: 100 * time.Millisecond}}
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "host/req-1")
+
+	tests := []struct {
+		name    string
+		level   pgx.LogLevel
+		msg     string
+		data    map[string]interface{}
+		entry   logrus.Level
+		message string
+		fields  logrus.Fields
+	}{
+		{
+			name:  "query",
+			level: pgx.LogLevelInfo,
+			msg:   "Query",
+			data: map[string]interface{}{
+				"sql":      "SELECT id\n\t\tFROM article WHERE slug = $1",
+				"args":     []interface{}{"secret"},
+				"time":     10 * time.Millisecond,
+				"rowCount": 1,
+			},
+			entry:   logrus.DebugLevel,
+			message: "query",
+			fields: logrus.Fields{
+				"query":       "Query",
+				"req_id":      "host/req-1",
+				"sql":         "SELECT id FROM article WHERE slug = $1",
+				"args":        []interface{}{"<string>"},
+				"duration_ms": float64(10),
+				"rows":        1,
+			},
+		},
+		{
+			name:  "slow exec",
+			level: pgx.LogLevelInfo,
+			msg:   "Exec",
+			data: map[string]interface{}{
+				"sql":        "DELETE FROM article",
+				"time":       time.Second,
+				"commandTag": pgconn.CommandTag("DELETE 3"),
+			},
+			entry:   logrus.WarnLevel,
+			message: "slow query",
+			fields: logrus.Fields{
+				"query":       "Exec",
+				"req_id":      "host/req-1",
+				"sql":         "DELETE FROM article",
+				"duration_ms": float64(1000),
+				"rows":        int64(3),
+			},
+		},
+		{
+			name:  "failed",
+			level: pgx.LogLevelError,
+			msg:   "Query",
+			data: map[string]interface{}{
+				"sql": "SELECT 1",
+				"err": errors.New("canceled"),
+			},
+			entry:   logrus.ErrorLevel,
+			message: "query failed",
+			fields: logrus.Fields{
+				"query":  "Query",
+				"req_id": "host/req-1",
+				"sql":    "SELECT 1",
+				"error":  errors.New("canceled"),
+			},
+		},
+	}
+	// Subtests are sequential, they share the hook.
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			hook.Reset()
+			l.Log(ctx, tt.level, tt.msg, tt.data)
+
+			entry := hook.LastEntry()
+			require.NotNil(t, entry)
+			assert.Equal(t, tt.entry, entry.Level)
+			assert.Equal(t, tt.message, entry.Message)
+			assert.Equal(t, tt.fields, entry.Data)
+		})
+	}
+
+	hook.Reset()
+	l.Log(ctx, pgx.LogLevelInfo, "Dialing PostgreSQL server", nil)
+	assert.Empty(t, hook.AllEntries(), "not a query")
+}
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type replica struct {
+	// name is host and port, URL is not logged because it may contain password.
+	name string
+	cfg  *pgxpool.Config
+	pool *pgxpool.Pool
+	// ejected is 1 while replica fails health checks, reads are not routed to it.
+	ejected int32
+}
+
+func (r *replica) healthy() bool {
+	return r.pool != nil && atomic.LoadInt32(&r.ejected) == 0
+}
+
+// connectReplicas creates lazy pools, so unavailable replica doesn't block start, it's ejected by check.
+func (d *DB) connectReplicas() error {
+	for _, r := range d.replicas {
+		if r.pool != nil {
+			continue
+		}
+		cfg := r.cfg.Copy()
+		cfg.LazyConnect = true
+		pool, err := pgxpool.ConnectConfig(context.Background(), cfg)
+		if err != nil {
+			return fmt.Errorf("could not create pool of replica %s: %w", r.name, err)
+		}
+		r.pool = pool
+	}
+	return nil
+}
+
+// CheckReplicas pings replicas, failed ones are ejected from reads until they respond again.
+func (d *DB) CheckReplicas(ctx context.Context) {
+	for _, r := range d.replicas {
+		if r.pool == nil {
+			continue
+		}
+
+		err := r.pool.Ping(ctx)
+		if err != nil && atomic.CompareAndSwapInt32(&r.ejected, 0, 1) {
+			d.Logger.WithError(err).Warnf("replica %s is unavailable, reads are routed to other replicas", r.name)
+		}
+		if err == nil && atomic.CompareAndSwapInt32(&r.ejected, 1, 0) {
+			d.Logger.Infof("replica %s is available again", r.name)
+		}
+	}
+}
+
+// Read returns transaction of ctx or pool for queries which tolerate replication lag: healthy replicas
+// are taken in round-robin order, primary is returned if there are no healthy replicas or ctx tracks writes and has one.
+func (d *DB) Read(ctx context.Context) Querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	if len(d.replicas) == 0 || hasWrites(ctx) {
+		return d.Session
+	}
+
+	n := uint32(len(d.replicas))
+	next := atomic.AddUint32(&d.next, 1)
+	for i := uint32(0); i < n; i++ {
+		if r := d.replicas[(next+i)%n]; r.healthy() {
+			return r.pool
+		}
+	}
+	return d.Session
+}
+
+// Write returns transaction of ctx or primary pool and records the write in ctx if it tracks writes.
+func (d *DB) Write(ctx context.Context) Querier {
+	markWrite(ctx)
+	return d.Primary(ctx)
+}
+
+// Primary returns transaction of ctx or primary pool, it's for reads which must not lag.
+func (d *DB) Primary(ctx context.Context) Querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return d.Session
+}
+
+func markWrite(ctx context.Context) {
+	if w, ok := ctx.Value(writesKey{}).(*int32); ok {
+		atomic.StoreInt32(w, 1)
+	}
+}
+
+type writesKey struct{}
+
+// TrackWrites returns ctx in which reads after a write are served by primary, so they see the written data.
+func TrackWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writesKey{}, new(int32))
+}
+
+// TrackWritesHandler enables read-your-writes within each request.
+func TrackWritesHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(TrackWrites(r.Context())))
+	})
+}
+
+func hasWrites(ctx context.Context) bool {
+	w, ok := ctx.Value(writesKey{}).(*int32)
+	return ok && atomic.LoadInt32(w) == 1
+}
@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agalitsyn/go-app/internal/pkg/log"
+)
+
+func TestDB_Read(t *testing.T) {
+	t.Parallel()
+
+	// Pools are lazy, so nothing has to listen on the ports until replicas are checked.
+	db, err := New("postgres://postgres@127.0.0.1:1/postgres", Options{
+		ReplicaURLs: []string{"postgres://postgres@127.0.0.1:2/postgres", "postgres://postgres@127.0.0.1:3/postgres"},
+	}, log.New("text", "error", ioutil.Discard))
+	require.NoError(t, err)
+	require.NoError(t, db.ConnectLazy())
+	defer db.Close()
+
+	first, second := db.replicas[0].pool, db.replicas[1].pool
+	ctx := context.Background()
+
+	t.Run("round-robin", func(t *testing.T) {
+		got := map[interface{}]int{}
+		for i := 0; i < 4; i++ {
+			got[db.Read(ctx)]++
+		}
+		assert.Equal(t, map[interface{}]int{first: 2, second: 2}, got)
+	})
+
+	t.Run("read your writes", func(t *testing.T) {
+		ctx := TrackWrites(ctx)
+		assert.NotSame(t, db.Session, db.Read(ctx))
+
+		assert.Same(t, db.Session, db.Write(ctx))
+		assert.Same(t, db.Session, db.Read(ctx))
+		assert.NotSame(t, db.Session, db.Read(context.Background()), "writes are tracked per ctx")
+	})
+
+	t.Run("ejection", func(t *testing.T) {
+		db.CheckReplicas(ctx)
+		assert.Same(t, db.Session, db.Read(ctx), "all replicas are down")
+	})
+}
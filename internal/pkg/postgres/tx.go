@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	serializationFailureCode = "40001"
+	deadlockDetectedCode     = "40P01"
+
+	txRetryBackoff = 10 * time.Millisecond
+)
+
+// Querier is implemented by pool and transaction, so queries don't depend on where they are executed.
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+type TxOptions struct {
+	IsoLevel pgx.TxIsoLevel
+	// MaxAttempts limits runs of a transaction which failed because of serialization failure or deadlock.
+	MaxAttempts int
+}
+
+// TxManager runs functions in transaction carried by ctx, DB.Read and DB.Write return it,
+// so repositories join the transaction transparently.
+type TxManager struct {
+	db   *DB
+	opts TxOptions
+}
+
+func NewTxManager(db *DB, opts TxOptions) *TxManager {
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 1
+	}
+	return &TxManager{db: db, opts: opts}
+}
+
+type txKey struct{}
+
+// InTx runs fn in transaction, which is committed if fn succeeds. If ctx already has transaction fn joins it.
+// Transaction is retried from scratch on serialization failure or deadlock, so fn must not have side effects
+// besides queries.
+func (m *TxManager) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	var err error
+	for attempt := 1; attempt <= m.opts.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			wait := time.Duration(attempt-1)*txRetryBackoff + time.Duration(rand.Int63n(int64(txRetryBackoff)))
+			m.db.Logger.Debugf("attempt %d: retrying transaction in %s: %s", attempt, wait, err)
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+
+		err = m.run(ctx, fn)
+		if !isRetryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (m *TxManager) run(ctx context.Context, fn func(ctx context.Context) error) error {
+	// Transaction may write, so following reads of ctx tracking writes go to primary.
+	markWrite(ctx)
+	// Deadline is applied to transaction only, so connection isn't reset on release.
+	tx, err := m.db.Session.BeginTx(context.WithValue(ctx, localTimeoutKey{}, true), pgx.TxOptions{IsoLevel: m.opts.IsoLevel})
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	// Rollback after commit is no-op.
+	defer tx.Rollback(context.Background())
+	if err = m.db.timeouts.setLocal(ctx, tx); err != nil {
+		return err
+	}
+
+	if err = fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
+
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == serializationFailureCode || pgErr.Code == deadlockDetectedCode)
+}
+
+// ParseIsoLevel converts isolation level name, e.g. "repeatable-read", into pgx value.
+func ParseIsoLevel(name string) (pgx.TxIsoLevel, error) {
+	switch name {
+	case "read-committed":
+		return pgx.ReadCommitted, nil
+	case "repeatable-read":
+		return pgx.RepeatableRead, nil
+	case "serializable":
+		return pgx.Serializable, nil
+	}
+	return "", fmt.Errorf("unknown isolation level %q", name)
+}
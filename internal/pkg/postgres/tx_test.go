@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIsoLevel(t *testing.T) {
+	t.Parallel()
+
+	level, err := ParseIsoLevel("repeatable-read")
+	require.NoError(t, err)
+	assert.Equal(t, pgx.RepeatableRead, level)
+
+	_, err = ParseIsoLevel("snapshot")
+	assert.Error(t, err)
+}
+
+func TestIsRetryable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		ok   bool
+	}{
+		{name: "serialization failure", err: &pgconn.PgError{Code: serializationFailureCode}, ok: true},
+		{name: "deadlock", err: fmt.Errorf("could not store tags: %w", &pgconn.PgError{Code: deadlockDetectedCode}), ok: true},
+		{name: "unique violation", err: &pgconn.PgError{Code: "23505"}, ok: false},
+		{name: "nil", err: nil, ok: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.ok, isRetryable(tt.err))
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package response
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/render"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	MediaTypeJSON    = "application/json"
+	MediaTypeXML     = "application/xml"
+	MediaTypeCSV     = "text/csv"
+	MediaTypeMsgPack = "application/msgpack"
+)
+
+// EncodeFunc writes v to w in a particular media type.
+type EncodeFunc func(w io.Writer, v interface{}) error
+
+// Format describes a registered response representation.
+type Format struct {
+	MediaType string
+	// Aliases are additional media types which select this format, e.g. text/xml.
+	Aliases []string
+	// ListOnly formats can represent collections only, e.g. CSV.
+	ListOnly bool
+	Encode   EncodeFunc
+	// EncodeEmpty writes empty list given an example item, e.g. CSV header. Encode is used instead
+	// if it's nil or the item is unknown, see Page.Item.
+	EncodeEmpty EncodeFunc
+}
+
+type registry struct {
+	mu      sync.RWMutex
+	formats []Format
+}
+
+var formats = &registry{}
+
+func init() {
+	// The first registered format is used when client accepts anything.
+	RegisterFormat(Format{MediaType: MediaTypeJSON, Aliases: []string{"text/javascript"}, Encode: encodeJSON})
+	RegisterFormat(Format{MediaType: MediaTypeXML, Aliases: []string{"text/xml"}, Encode: encodeXML})
+	RegisterFormat(Format{MediaType: MediaTypeCSV, ListOnly: true, Encode: encodeCSV, EncodeEmpty: encodeCSVHeader})
+	RegisterFormat(Format{MediaType: MediaTypeMsgPack, Aliases: []string{"application/x-msgpack"}, Encode: encodeMsgPack})
+
+	render.Respond = Respond
+}
+
+// RegisterFormat adds a new format or replaces a format with the same media type.
+func RegisterFormat(f Format) {
+	formats.mu.Lock()
+	defer formats.mu.Unlock()
+
+	for i := range formats.formats {
+		if formats.formats[i].MediaType == f.MediaType {
+			formats.formats[i] = f
+			return
+		}
+	}
+	formats.formats = append(formats.formats, f)
+}
+
+// Negotiate picks the best format for the request Accept header.
+// Formats which can't represent a single item are skipped unless list is true.
+func Negotiate(r *http.Request, list bool) (Format, bool) {
+	formats.mu.RLock()
+	defer formats.mu.RUnlock()
+
+	for _, accepted := range parseAccept(r.Header.Get("Accept")) {
+		for _, f := range formats.formats {
+			if f.ListOnly && !list {
+				continue
+			}
+			if f.matches(accepted) {
+				return f, true
+			}
+		}
+	}
+	return Format{}, false
+}
+
+func (f Format) matches(mediaType string) bool {
+	if mediaType == "*/*" {
+		return true
+	}
+	for _, t := range append([]string{f.MediaType}, f.Aliases...) {
+		if mediaType == t {
+			return true
+		}
+		// Aliases count for ranges too, so text/* selects JSON by text/javascript if CSV can't be used.
+		if strings.HasSuffix(mediaType, "/*") && strings.HasPrefix(t, strings.TrimSuffix(mediaType, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAccept returns accepted media types ordered by preference: by quality, then more specific first,
+// e.g. text/csv before text/* before */*. Empty header means the client accepts anything.
+func parseAccept(header string) []string {
+	if strings.TrimSpace(header) == "" {
+		return []string{"*/*"}
+	}
+
+	type accepted struct {
+		mediaType string
+		q         float64
+		// specificity is 2 for exact type, 1 for type/* and 0 for */*.
+		specificity int
+	}
+	var list []accepted
+	for _, field := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(field))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		specificity := 2
+		if mediaType == "*/*" {
+			specificity = 0
+		} else if strings.HasSuffix(mediaType, "/*") {
+			specificity = 1
+		}
+		list = append(list, accepted{mediaType: mediaType, q: q, specificity: specificity})
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].q != list[j].q {
+			return list[i].q > list[j].q
+		}
+		return list[i].specificity > list[j].specificity
+	})
+
+	res := make([]string, 0, len(list))
+	for _, a := range list {
+		res = append(res, a.mediaType)
+	}
+	return res
+}
+
+// Respond replaces render.Respond and encodes v in the format negotiated with the client.
+// If no format is acceptable, it responds with 406 Not Acceptable.
+func Respond(w http.ResponseWriter, r *http.Request, v interface{}) {
+	w.Header().Add("Vary", "Accept")
+
+	l, list := v.([]render.Renderer)
+	f, ok := Negotiate(r, list)
+	if ok && list && len(l) == 0 && f.EncodeEmpty != nil {
+		if item, ok := r.Context().Value(listItemKey{}).(render.Renderer); ok {
+			f.Encode, v = f.EncodeEmpty, item
+		}
+	}
+	if !ok {
+		if _, isErr := v.(*ErrResponse); isErr {
+			// Errors are always delivered, even if client can't accept them.
+			f = defaultFormat()
+		} else {
+			notAcceptable(w, r)
+			return
+		}
+	}
+
+	write(w, r, f, v)
+}
+
+func write(w http.ResponseWriter, r *http.Request, f Format, v interface{}) {
+	buf := &bytes.Buffer{}
+	if err := f.Encode(buf, v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", f.MediaType)
+	if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
+		w.WriteHeader(status)
+	}
+	w.Write(buf.Bytes())
+}
+
+func notAcceptable(w http.ResponseWriter, r *http.Request) {
+	var supported []string
+	formats.mu.RLock()
+	for _, f := range formats.formats {
+		supported = append(supported, f.MediaType)
+	}
+	formats.mu.RUnlock()
+
+	errResp := ErrNotAcceptable(fmt.Errorf("supported media types: %s", strings.Join(supported, ", ")))
+	render.Status(r, http.StatusNotAcceptable)
+	write(w, r, defaultFormat(), errResp)
+}
+
+func defaultFormat() Format {
+	formats.mu.RLock()
+	defer formats.mu.RUnlock()
+	return formats.formats[0]
+}
+
+func encodeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(true)
+	return enc.Encode(v)
+}
+
+type xmlList struct {
+	XMLName xml.Name      `xml:"list"`
+	Items   []interface{} `xml:"item"`
+}
+
+func encodeXML(w io.Writer, v interface{}) error {
+	if l, ok := v.([]render.Renderer); ok {
+		items := make([]interface{}, 0, len(l))
+		for _, item := range l {
+			items = append(items, item)
+		}
+		v = xmlList{Items: items}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+func encodeMsgPack(w io.Writer, v interface{}) error {
+	enc := msgpack.NewEncoder(w)
+	// Keep field names the same as in JSON representation.
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+// encodeCSV writes a list of flat structs as CSV with a header row.
+// Column names are taken from json tags.
+func encodeCSV(w io.Writer, v interface{}) error {
+	l, ok := v.([]render.Renderer)
+	if !ok {
+		return errors.New("csv: only lists are supported")
+	}
+
+	cw := csv.NewWriter(w)
+	for i, item := range l {
+		header, record, err := csvRow(item)
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			if err := cw.Write(header); err != nil {
+				return err
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// encodeCSVHeader writes header row of an empty list with columns of the example item.
+func encodeCSVHeader(w io.Writer, item interface{}) error {
+	header, _, err := csvRow(item)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	if err = cw.Write(header); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvRow(item interface{}) (header, record []string, err error) {
+	rv := reflect.Indirect(reflect.ValueOf(item))
+	if rv.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("csv: unsupported item type %T", item)
+	}
+
+	for j := 0; j < rv.NumField(); j++ {
+		field := rv.Type().Field(j)
+		name := csvColumnName(field)
+		if name == "" {
+			continue
+		}
+		header = append(header, name)
+		record = append(record, fmt.Sprint(rv.Field(j).Interface()))
+	}
+	return header, record, nil
+}
+
+func csvColumnName(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		return ""
+	}
+	switch field.Type.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Interface, reflect.Ptr, reflect.Func, reflect.Chan:
+		return ""
+	}
+
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
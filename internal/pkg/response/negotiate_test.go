@@ -0,0 +1,197 @@
+package response
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/render"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type testItem struct {
+	XMLName xml.Name `json:"-" xml:"item"`
+
+	ID   int    `json:"id" xml:"id"`
+	Name string `json:"name" xml:"name"`
+}
+
+func (*testItem) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func TestMustRenderList(t *testing.T) {
+	t.Parallel()
+
+	list := []render.Renderer{
+		&testItem{ID: 1, Name: "foo"},
+		&testItem{ID: 2, Name: "bar"},
+	}
+
+	tests := []struct {
+		name        string
+		accept      string
+		code        int
+		contentType string
+		body        string
+	}{
+		{
+			name:        "no accept header",
+			code:        http.StatusOK,
+			contentType: MediaTypeJSON,
+			body:        `[{"id":1,"name":"foo"},{"id":2,"name":"bar"}]` + "\n",
+		},
+		{
+			name:        "json",
+			accept:      "application/json",
+			code:        http.StatusOK,
+			contentType: MediaTypeJSON,
+			body:        `[{"id":1,"name":"foo"},{"id":2,"name":"bar"}]` + "\n",
+		},
+		{
+			name:        "xml",
+			accept:      "text/xml",
+			code:        http.StatusOK,
+			contentType: MediaTypeXML,
+			body:        xml.Header + `<list><item><id>1</id><name>foo</name></item><item><id>2</id><name>bar</name></item></list>`,
+		},
+		{
+			name:        "csv",
+			accept:      "text/csv",
+			code:        http.StatusOK,
+			contentType: MediaTypeCSV,
+			body:        "id,name\n1,foo\n2,bar\n",
+		},
+		{
+			name:        "quality",
+			accept:      "application/json;q=0.5, text/csv",
+			code:        http.StatusOK,
+			contentType: MediaTypeCSV,
+			body:        "id,name\n1,foo\n2,bar\n",
+		},
+		{
+			name:        "specific before range",
+			accept:      "*/*, text/*, text/csv",
+			code:        http.StatusOK,
+			contentType: MediaTypeCSV,
+			body:        "id,name\n1,foo\n2,bar\n",
+		},
+		{
+			name:        "not acceptable",
+			accept:      "image/png",
+			code:        http.StatusNotAcceptable,
+			contentType: MediaTypeJSON,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			MustRenderList(w, req, list)
+
+			resp := w.Result()
+			assert.Equal(t, tt.code, resp.StatusCode)
+			assert.Equal(t, tt.contentType, resp.Header.Get("Content-Type"))
+
+			if tt.body != "" {
+				body, err := ioutil.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tt.body, string(body))
+			}
+		})
+	}
+}
+
+func TestMustRender(t *testing.T) {
+	t.Parallel()
+
+	t.Run("msgpack", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", MediaTypeMsgPack)
+
+		MustRender(w, req, &testItem{ID: 1, Name: "foo"})
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, MediaTypeMsgPack, resp.Header.Get("Content-Type"))
+
+		var got map[string]interface{}
+		require.NoError(t, msgpack.NewDecoder(resp.Body).Decode(&got))
+		assert.Equal(t, "foo", got["name"])
+	})
+
+	t.Run("csv is list only", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", MediaTypeCSV)
+
+		MustRender(w, req, &testItem{ID: 1, Name: "foo"})
+
+		assert.Equal(t, http.StatusNotAcceptable, w.Result().StatusCode)
+	})
+
+	t.Run("range matches alias", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", "text/*")
+
+		MustRender(w, req, &testItem{ID: 1, Name: "foo"})
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, MediaTypeJSON, resp.Header.Get("Content-Type"))
+	})
+
+	t.Run("errors are always rendered", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", MediaTypeCSV)
+
+		MustRender(w, req, ErrNotFound(errors.New("missing")))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, MediaTypeJSON, resp.Header.Get("Content-Type"))
+	})
+}
+
+func TestMustRenderPage_empty(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		page Page
+		body string
+	}{
+		{name: "with item", page: Page{Item: &testItem{}}, body: "id,name\n"},
+		{name: "without item", page: Page{}, body: ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept", MediaTypeCSV)
+
+			MustRenderPage(w, req, nil, tt.page)
+
+			resp := w.Result()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, MediaTypeCSV, resp.Header.Get("Content-Type"))
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.body, string(body))
+		})
+	}
+}
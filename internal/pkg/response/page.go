@@ -0,0 +1,129 @@
+package response
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/render"
+
+	mw "github.com/agalitsyn/go-app/internal/pkg/middleware"
+)
+
+// Page describes position of the list in the whole collection.
+type Page struct {
+	// Total is nil when total count is unknown.
+	Total  *int64
+	Limit  uint64
+	Offset uint64
+	// Item is an example of list items, formats like CSV take columns of empty list from it.
+	Item render.Renderer
+}
+
+type listItemKey struct{}
+
+// ListEnvelope wraps list with metadata, e.g. {data, meta: {total, next, prev}, links}.
+type ListEnvelope struct {
+	XMLName xml.Name `json:"-" xml:"list"`
+
+	Data  []render.Renderer `json:"data" xml:"data>item"`
+	Meta  ListMeta          `json:"meta" xml:"meta"`
+	Links ListLinks         `json:"links" xml:"links"`
+}
+
+type ListMeta struct {
+	Total *int64 `json:"total,omitempty" xml:"total,omitempty"`
+	// Next and Prev are offsets of neighbour pages.
+	Next *uint64 `json:"next,omitempty" xml:"next,omitempty"`
+	Prev *uint64 `json:"prev,omitempty" xml:"prev,omitempty"`
+}
+
+type ListLinks struct {
+	Self string `json:"self" xml:"self"`
+	Next string `json:"next,omitempty" xml:"next,omitempty"`
+	Prev string `json:"prev,omitempty" xml:"prev,omitempty"`
+}
+
+func (e *ListEnvelope) Render(w http.ResponseWriter, r *http.Request) error {
+	for _, v := range e.Data {
+		if err := v.Render(w, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MustRenderPage renders list in envelope when it's enabled for the route with middleware.ListEnvelope,
+// otherwise it falls back to MustRenderList.
+func MustRenderPage(w http.ResponseWriter, r *http.Request, l []render.Renderer, page Page) {
+	if page.Item != nil {
+		r = r.WithContext(context.WithValue(r.Context(), listItemKey{}, page.Item))
+	}
+	if !ListEnvelopeEnabled(r) {
+		MustRenderList(w, r, l)
+		return
+	}
+
+	// Formats like CSV can't hold metadata, so they get bare list.
+	if f, ok := Negotiate(r, true); ok && f.ListOnly {
+		MustRenderList(w, r, l)
+		return
+	}
+
+	MustRender(w, r, NewListEnvelope(r, l, page))
+}
+
+// ListEnvelopeEnabled reports whether list metadata will be rendered,
+// handlers can use it to skip calculating total count.
+func ListEnvelopeEnabled(r *http.Request) bool {
+	enabled, _ := r.Context().Value(mw.ListEnvelopeContextKey).(bool)
+	return enabled
+}
+
+func NewListEnvelope(r *http.Request, l []render.Renderer, page Page) *ListEnvelope {
+	if l == nil {
+		l = []render.Renderer{}
+	}
+
+	env := &ListEnvelope{
+		Data: l,
+		Meta: ListMeta{Total: page.Total},
+		Links: ListLinks{
+			Self: r.URL.RequestURI(),
+		},
+	}
+	if page.Limit == 0 {
+		return env
+	}
+
+	hasNext := uint64(len(l)) == page.Limit
+	if page.Total != nil {
+		hasNext = page.Offset+page.Limit < uint64(*page.Total)
+	}
+	if hasNext {
+		next := page.Offset + page.Limit
+		env.Meta.Next = &next
+		env.Links.Next = pageURL(r, next, page.Limit)
+	}
+
+	if page.Offset > 0 {
+		var prev uint64
+		if page.Offset > page.Limit {
+			prev = page.Offset - page.Limit
+		}
+		env.Meta.Prev = &prev
+		env.Links.Prev = pageURL(r, prev, page.Limit)
+	}
+
+	return env
+}
+
+func pageURL(r *http.Request, offset, limit uint64) string {
+	u := *r.URL
+	q := u.Query()
+	q.Set("offset", strconv.FormatUint(offset, 10))
+	q.Set("limit", strconv.FormatUint(limit, 10))
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
@@ -1,6 +1,7 @@
 package response
 
 import (
+	"encoding/xml"
 	"net/http"
 
 	"github.com/go-chi/render"
@@ -24,12 +25,14 @@ func MustRenderList(resp http.ResponseWriter, req *http.Request, l []render.Rend
 // helps reveal information on the error, setting it on Err, and in the Render()
 // method, using it to set the application-specific error code in AppCode.
 type ErrResponse struct {
-	Err            error `json:"-"` // low-level runtime error
-	HTTPStatusCode int   `json:"-"` // http response status code
+	XMLName xml.Name `json:"-" xml:"error"`
 
-	StatusText string `json:"status"`          // user-level status message
-	AppCode    int64  `json:"code,omitempty"`  // application-specific error code
-	ErrorText  string `json:"error,omitempty"` // application-level error message, for debugging
+	Err            error `json:"-" xml:"-"` // low-level runtime error
+	HTTPStatusCode int   `json:"-" xml:"-"` // http response status code
+
+	StatusText string `json:"status" xml:"status"`                   // user-level status message
+	AppCode    int64  `json:"code,omitempty" xml:"code,omitempty"`   // application-specific error code
+	ErrorText  string `json:"error,omitempty" xml:"error,omitempty"` // application-level error message, for debugging
 }
 
 func (e *ErrResponse) Render(w http.ResponseWriter, r *http.Request) error {
@@ -81,3 +84,48 @@ func ErrBadRequest(err error) render.Renderer {
 		ErrorText:      err.Error(),
 	}
 }
+
+func ErrConflict(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusConflict,
+		StatusText:     http.StatusText(http.StatusConflict),
+		ErrorText:      err.Error(),
+	}
+}
+
+func ErrNotAcceptable(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusNotAcceptable,
+		StatusText:     http.StatusText(http.StatusNotAcceptable),
+		ErrorText:      err.Error(),
+	}
+}
+
+func ErrServiceUnavailable(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusServiceUnavailable,
+		StatusText:     http.StatusText(http.StatusServiceUnavailable),
+		ErrorText:      err.Error(),
+	}
+}
+
+func ErrTooManyRequests(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusTooManyRequests,
+		StatusText:     http.StatusText(http.StatusTooManyRequests),
+		ErrorText:      err.Error(),
+	}
+}
+
+func ErrGatewayTimeout(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusGatewayTimeout,
+		StatusText:     http.StatusText(http.StatusGatewayTimeout),
+		ErrorText:      err.Error(),
+	}
+}
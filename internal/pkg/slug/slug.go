@@ -0,0 +1,88 @@
+// Package slug generates URL friendly identifiers from arbitrary titles.
+package slug
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// transliteration of letters which are not decomposed into Latin base letter with marks.
+var transliteration = map[rune]string{
+	// Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya", 'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g", 'ў': "u",
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o",
+	// Latin letters without decomposition
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d", 'þ': "th", 'ł': "l",
+	'ı': "i",
+}
+
+// Make converts title into lowercase slug of words separated by hyphen.
+// Diacritics are stripped, Cyrillic and Greek are transliterated into Latin and
+// letters of other scripts are kept as is. Result is empty if title has no letters or digits.
+func Make(title string) string {
+	var sb strings.Builder
+	hyphen := false
+	for _, r := range norm.NFC.String(strings.ToLower(title)) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			hyphen = true
+			continue
+		}
+
+		s := transliterate(r)
+		if s == "" {
+			continue
+		}
+
+		if hyphen && sb.Len() > 0 {
+			sb.WriteByte('-')
+		}
+		hyphen = false
+		sb.WriteString(s)
+	}
+	return sb.String()
+}
+
+// transliterate converts letter into Latin, stripping diacritics, e.g. é becomes e and έ becomes e.
+func transliterate(r rune) string {
+	if s, ok := transliteration[r]; ok {
+		return s
+	}
+
+	var sb strings.Builder
+	for _, c := range norm.NFD.String(string(r)) {
+		if unicode.Is(unicode.Mn, c) {
+			continue
+		}
+		if s, ok := transliteration[c]; ok {
+			sb.WriteString(s)
+		} else {
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+// Unique returns base if it's not taken, otherwise base with the first free numeric suffix
+// starting from 2, e.g. "foo-2", "foo-3".
+func Unique(base string, taken func(slug string) bool) string {
+	if !taken(base) {
+		return base
+	}
+	for n := 2; ; n++ {
+		s := base + "-" + strconv.Itoa(n)
+		if !taken(s) {
+			return s
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package slug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMake(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		title string
+		slug  string
+	}{
+		{title: "New Book", slug: "new-book"},
+		{title: "  Hello, World!  ", slug: "hello-world"},
+		{title: "Go 1.16 release", slug: "go-1-16-release"},
+		{title: "Crème brûlée à la française", slug: "creme-brulee-a-la-francaise"},
+		{title: "Straße", slug: "strasse"},
+		{title: "Привет, мир", slug: "privet-mir"},
+		{title: "Щука и ёж", slug: "shchuka-i-ezh"},
+		{title: "Йогурт", slug: "yogurt"},
+		{title: "Καλημέρα κόσμε", slug: "kalimera-kosme"},
+		{title: "東京 guide", slug: "東京-guide"},
+		{title: "!!!", slug: ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.title, func(t *testing.T) {
+			assert.Equal(t, tt.slug, Make(tt.title))
+		})
+	}
+}
+
+func TestUnique(t *testing.T) {
+	t.Parallel()
+
+	taken := map[string]bool{"foo": true, "foo-2": true, "bar-2": true}
+	isTaken := func(s string) bool { return taken[s] }
+
+	assert.Equal(t, "foo-3", Unique("foo", isTaken))
+	assert.Equal(t, "bar", Unique("bar", isTaken))
+}
@@ -3,22 +3,88 @@ package storage
 import (
 	"context"
 	"errors"
+
+	"github.com/agalitsyn/go-app/internal/pkg/filter"
 )
 
-var ErrArticleNotFound = errors.New("not found")
+var (
+	ErrArticleNotFound = errors.New("not found")
+	ErrArticleConflict = errors.New("conflict")
+)
 
 type Article struct {
-	ID    int
-	Title string
-	Slug  string
+	ID       int
+	Title    string
+	Slug     string
+	AuthorID int
+
+	// Related resources are loaded only on demand, see ArticleFilter.
+	Author *Author
+	Tags   []string
+}
+
+// Article fields which can be selected with ArticleFilter.Fields.
+const (
+	ArticleFieldID       = "id"
+	ArticleFieldTitle    = "title"
+	ArticleFieldSlug     = "slug"
+	ArticleFieldAuthorID = "author_id"
+)
+
+var ArticleFields = []string{
+	ArticleFieldID,
+	ArticleFieldTitle,
+	ArticleFieldSlug,
+	ArticleFieldAuthorID,
 }
 
 type ArticleFilter struct {
-	Limit uint64
+	Limit  uint64
+	Offset uint64
+
+	// Fields limits loaded fields, empty means all. ID is always loaded.
+	Fields []string
+	// WithAuthor and WithTags load related resources in batch for all found articles.
+	WithAuthor bool
+	WithTags   bool
+
+	// Expr is an optional filter expression validated against ArticleFilterSchema.
+	Expr filter.Expr
+}
+
+// ArticleFilterFieldTag matches articles having the tag.
+const ArticleFilterFieldTag = "tag"
+
+// ArticleFilterSchema lists fields and operators allowed in article filter expressions.
+var ArticleFilterSchema = filter.Schema{
+	ArticleFieldID:        {Type: filter.Int, Operators: filter.IntOperators},
+	ArticleFieldTitle:     {Type: filter.String, Operators: filter.StringOperators},
+	ArticleFieldSlug:      {Type: filter.String, Operators: filter.StringOperators},
+	ArticleFilterFieldTag: {Type: filter.String, Operators: filter.StringOperators},
 }
 
+// CountMode defines how total number of rows is calculated.
+type CountMode int
+
+const (
+	// CountExact performs full count, which can be slow on large tables.
+	CountExact CountMode = iota
+	// CountEstimate uses query planner statistics, it's cheap but approximate.
+	CountEstimate
+)
+
 type ArticleRepository interface {
 	FilterArticles(ctx context.Context, params ArticleFilter) ([]Article, error)
+	CountArticles(ctx context.Context, params ArticleFilter, mode CountMode) (int64, error)
+	// GetArticle finds article by current or previous slug, so returned slug may differ from requested.
+	GetArticle(ctx context.Context, slug string) (Article, error)
+	// CreateArticle inserts new article, ErrArticleConflict is returned if slug is used by another article.
+	CreateArticle(ctx context.Context, article Article) error
+	// StoreArticles inserts new articles and updates existing ones matched by slug.
 	StoreArticles(ctx context.Context, articles []Article) error
+	// UpdateArticle updates article found by slug and keeps old slug in history on rename.
+	UpdateArticle(ctx context.Context, slug string, article Article) error
 	DeleteArticles(ctx context.Context, articles []Article) error
+	// UniqueArticleSlug returns base or base with numeric suffix which is not used by any article.
+	UniqueArticleSlug(ctx context.Context, base string) (string, error)
 }
@@ -0,0 +1,6 @@
+package storage
+
+type Author struct {
+	ID   int
+	Name string
+}
@@ -0,0 +1,180 @@
+// Package cache decorates storages with in-process cache of read results.
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/agalitsyn/go-app/internal/pkg/filter"
+	"github.com/agalitsyn/go-app/internal/storage"
+)
+
+type Options struct {
+	// Size is maximum number of cached results.
+	Size int
+	// TTL bounds staleness of results if invalidation is missed, zero means results don't expire.
+	TTL time.Duration
+}
+
+type Stats struct {
+	Size          int   `json:"size"`
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	Invalidations int64 `json:"invalidations"`
+}
+
+// ArticleStorage caches lists, counts and single articles of next storage. Concurrent misses of the same key
+// are collapsed into a single call. Any write invalidates the whole cache, changes made by other instances
+// must be reported with Invalidate.
+//
+// Cached articles are shared between callers, so their Author and Tags must not be modified.
+// Reads within a transaction should use next storage, they would get results committed before.
+type ArticleStorage struct {
+	next  storage.ArticleRepository
+	group singleflight.Group
+	now   func() time.Time
+
+	mu            sync.Mutex
+	lru           *lru
+	generation    uint64
+	hits          int64
+	misses        int64
+	invalidations int64
+}
+
+func NewArticleStorage(next storage.ArticleRepository, opts Options) *ArticleStorage {
+	return &ArticleStorage{next: next, now: time.Now, lru: newLRU(opts.Size, opts.TTL)}
+}
+
+func (s *ArticleStorage) FilterArticles(ctx context.Context, params storage.ArticleFilter) ([]storage.Article, error) {
+	key := fmt.Sprintf("filter:%d:%d:%s:%t:%t:%s", params.Limit, params.Offset, strings.Join(params.Fields, ","),
+		params.WithAuthor, params.WithTags, exprKey(params.Expr))
+	v, err := s.load(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return s.next.FilterArticles(ctx, params)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Callers may append to or sort the list.
+	return append([]storage.Article(nil), v.([]storage.Article)...), nil
+}
+
+func (s *ArticleStorage) CountArticles(ctx context.Context, params storage.ArticleFilter, mode storage.CountMode) (int64, error) {
+	key := fmt.Sprintf("count:%d:%s", mode, exprKey(params.Expr))
+	v, err := s.load(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return s.next.CountArticles(ctx, params, mode)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(int64), nil
+}
+
+func (s *ArticleStorage) GetArticle(ctx context.Context, slug string) (storage.Article, error) {
+	v, err := s.load(ctx, "get:"+slug, func(ctx context.Context) (interface{}, error) {
+		return s.next.GetArticle(ctx, slug)
+	})
+	if err != nil {
+		return storage.Article{}, err
+	}
+	return v.(storage.Article), nil
+}
+
+func (s *ArticleStorage) CreateArticle(ctx context.Context, article storage.Article) error {
+	defer s.Invalidate()
+	return s.next.CreateArticle(ctx, article)
+}
+
+func (s *ArticleStorage) StoreArticles(ctx context.Context, articles []storage.Article) error {
+	defer s.Invalidate()
+	return s.next.StoreArticles(ctx, articles)
+}
+
+func (s *ArticleStorage) UpdateArticle(ctx context.Context, slug string, article storage.Article) error {
+	defer s.Invalidate()
+	return s.next.UpdateArticle(ctx, slug, article)
+}
+
+func (s *ArticleStorage) DeleteArticles(ctx context.Context, articles []storage.Article) error {
+	defer s.Invalidate()
+	return s.next.DeleteArticles(ctx, articles)
+}
+
+// UniqueArticleSlug is not cached, because its result is used for writes.
+func (s *ArticleStorage) UniqueArticleSlug(ctx context.Context, base string) (string, error) {
+	return s.next.UniqueArticleSlug(ctx, base)
+}
+
+// Invalidate drops all cached results. Loads which are in progress are not cached.
+func (s *ArticleStorage) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.generation++
+	s.invalidations++
+	s.lru.purge()
+}
+
+func (s *ArticleStorage) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Stats{Size: s.lru.len(), Hits: s.hits, Misses: s.misses, Invalidations: s.invalidations}
+}
+
+func (s *ArticleStorage) load(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	s.mu.Lock()
+	generation := s.generation
+	v, ok := s.lru.get(key, s.now())
+	if ok {
+		s.hits++
+	} else {
+		s.misses++
+	}
+	s.mu.Unlock()
+	if ok {
+		return v, nil
+	}
+
+	// Generation is a part of the key, so callers which came after invalidation don't join loads started before it.
+	v, err, _ := s.group.Do(strconv.FormatUint(generation, 10)+":"+key, func() (interface{}, error) {
+		v, err := fn(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		s.mu.Lock()
+		if s.generation == generation {
+			s.lru.add(key, v, s.now())
+		}
+		s.mu.Unlock()
+		return v, nil
+	})
+	if err != nil && ctx.Err() == nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		// Shared load was canceled by the caller which started it.
+		return fn(ctx)
+	}
+	return v, err
+}
+
+// exprKey formats filter expression, so equal expressions have equal keys.
+func exprKey(e filter.Expr) string {
+	switch e := e.(type) {
+	case nil:
+		return ""
+	case *filter.Logical:
+		return "(" + exprKey(e.Left) + " " + string(e.Op) + " " + exprKey(e.Right) + ")"
+	case *filter.Not:
+		return "not " + exprKey(e.X)
+	case *filter.Comparison:
+		return fmt.Sprintf("%s %s %#v", e.Field, e.Op, e.Values)
+	default:
+		return fmt.Sprintf("%#v", e)
+	}
+}
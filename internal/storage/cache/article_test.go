@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agalitsyn/go-app/internal/pkg/filter"
+	"github.com/agalitsyn/go-app/internal/storage"
+	"github.com/agalitsyn/go-app/internal/storage/memory"
+	"github.com/agalitsyn/go-app/internal/storage/storagetest"
+)
+
+type stubArticleStorage struct {
+	storage.ArticleRepository
+	calls int32
+	// wait blocks calls until closed if set.
+	wait chan struct{}
+}
+
+func (s *stubArticleStorage) FilterArticles(ctx context.Context, params storage.ArticleFilter) ([]storage.Article, error) {
+	atomic.AddInt32(&s.calls, 1)
+	if s.wait != nil {
+		<-s.wait
+	}
+	return []storage.Article{{ID: 1, Slug: "foo"}}, nil
+}
+
+func (s *stubArticleStorage) GetArticle(ctx context.Context, slug string) (storage.Article, error) {
+	atomic.AddInt32(&s.calls, 1)
+	if slug != "foo" {
+		return storage.Article{}, storage.ErrArticleNotFound
+	}
+	return storage.Article{ID: 1, Slug: slug}, nil
+}
+
+func (s *stubArticleStorage) DeleteArticles(ctx context.Context, articles []storage.Article) error {
+	return nil
+}
+
+func TestArticleStorage(t *testing.T) {
+	t.Parallel()
+
+	stub := &stubArticleStorage{}
+	s := NewArticleStorage(stub, Options{Size: 10, TTL: time.Minute})
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		article, err := s.GetArticle(ctx, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, "foo", article.Slug)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&stub.calls))
+
+	for i := 0; i < 2; i++ {
+		_, err := s.GetArticle(ctx, "bar")
+		assert.ErrorIs(t, err, storage.ErrArticleNotFound)
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&stub.calls), "errors are not cached")
+
+	require.NoError(t, s.DeleteArticles(ctx, []storage.Article{{Slug: "foo"}}))
+	s.GetArticle(ctx, "foo")
+	assert.Equal(t, int32(4), atomic.LoadInt32(&stub.calls), "invalidated on write")
+
+	now = now.Add(time.Minute)
+	s.GetArticle(ctx, "foo")
+	assert.Equal(t, int32(5), atomic.LoadInt32(&stub.calls), "expired")
+
+	assert.Equal(t, Stats{Size: 1, Hits: 2, Misses: 5, Invalidations: 1}, s.Stats())
+}
+
+func TestArticleStorage_singleflight(t *testing.T) {
+	t.Parallel()
+
+	stub := &stubArticleStorage{wait: make(chan struct{})}
+	s := NewArticleStorage(stub, Options{Size: 10})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			articles, err := s.FilterArticles(ctx, storage.ArticleFilter{Limit: 10})
+			assert.NoError(t, err)
+			assert.Len(t, articles, 1)
+		}()
+	}
+	require.Eventually(t, func() bool { return s.Stats().Misses == 10 }, time.Second, time.Millisecond)
+	close(stub.wait)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&stub.calls))
+}
+
+func TestArticleStorage_invalidateDuringLoad(t *testing.T) {
+	t.Parallel()
+
+	stub := &stubArticleStorage{wait: make(chan struct{})}
+	s := NewArticleStorage(stub, Options{Size: 10})
+	ctx := context.Background()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.FilterArticles(ctx, storage.ArticleFilter{})
+	}()
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&stub.calls) == 1 }, time.Second, time.Millisecond)
+	s.Invalidate()
+	close(stub.wait)
+	<-done
+
+	s.FilterArticles(ctx, storage.ArticleFilter{})
+	assert.Equal(t, int32(2), atomic.LoadInt32(&stub.calls), "result loaded before invalidation is not cached")
+}
+
+func TestArticleStorage_conformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestArticleRepository(t, func(t *testing.T) storage.ArticleRepository {
+		return NewArticleStorage(memory.NewArticleStorage(), Options{Size: 100, TTL: time.Minute})
+	})
+}
+
+func TestExprKey(t *testing.T) {
+	t.Parallel()
+
+	parse := func(input string) filter.Expr {
+		e, err := filter.ParseWithSchema(input, storage.ArticleFilterSchema)
+		require.NoError(t, err)
+		return e
+	}
+
+	assert.Equal(t, exprKey(parse(`title~"go" and id>1`)), exprKey(parse(`title ~ "go" and id > 1`)))
+	assert.NotEqual(t, exprKey(parse(`slug="1"`)), exprKey(parse(`slug="2"`)))
+	assert.NotEqual(t, exprKey(parse(`id=1 or id=2 and id=3`)), exprKey(parse(`(id=1 or id=2) and id=3`)))
+}
+
+func TestLRU(t *testing.T) {
+	t.Parallel()
+
+	c := newLRU(2, 0)
+	now := time.Now()
+	c.add("a", 1, now)
+	c.add("b", 2, now)
+	c.get("a", now)
+	c.add("c", 3, now)
+
+	_, ok := c.get("b", now)
+	assert.False(t, ok, "least recently used is evicted")
+	v, ok := c.get("a", now.Add(time.Hour))
+	assert.True(t, ok, "doesn't expire without ttl")
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 2, c.len())
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+)
+
+// lru evicts the least recently used entries above size and expired ones, it's not safe for concurrent use.
+type lru struct {
+	size  int
+	ttl   time.Duration
+	items map[string]*list.Element
+	order *list.List
+}
+
+type entry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// newLRU creates cache of size entries, zero ttl means entries don't expire.
+func newLRU(size int, ttl time.Duration) *lru {
+	return &lru{size: size, ttl: ttl, items: make(map[string]*list.Element), order: list.New()}
+}
+
+func (c *lru) get(key string, now time.Time) (interface{}, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if c.ttl > 0 && !now.Before(e.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+func (c *lru) add(key string, value interface{}, now time.Time) {
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	c.items[key] = c.order.PushFront(&entry{key: key, value: value, expires: now.Add(c.ttl)})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *lru) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*entry).key)
+}
+
+func (c *lru) purge() {
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+func (c *lru) len() int {
+	return c.order.Len()
+}
@@ -0,0 +1,93 @@
+// Package guard decorates storages with circuit breaker, so requests fail fast while database is failing.
+package guard
+
+import (
+	"context"
+	"errors"
+
+	"github.com/agalitsyn/go-app/internal/pkg/breaker"
+	"github.com/agalitsyn/go-app/internal/storage"
+)
+
+// IsFailure reports if error is a failure of storage backend, unlike not found, conflict
+// or canceled by client errors.
+func IsFailure(err error) bool {
+	return err != nil &&
+		!errors.Is(err, storage.ErrArticleNotFound) &&
+		!errors.Is(err, storage.ErrArticleConflict) &&
+		!errors.Is(err, context.Canceled)
+}
+
+type ArticleStorage struct {
+	next    storage.ArticleRepository
+	breaker *breaker.Breaker
+}
+
+// NewArticleStorage wraps calls of next with breaker, it should use IsFailure.
+func NewArticleStorage(next storage.ArticleRepository, b *breaker.Breaker) *ArticleStorage {
+	return &ArticleStorage{next: next, breaker: b}
+}
+
+func (s *ArticleStorage) FilterArticles(ctx context.Context, params storage.ArticleFilter) ([]storage.Article, error) {
+	var res []storage.Article
+	err := s.breaker.Do(func() error {
+		var err error
+		res, err = s.next.FilterArticles(ctx, params)
+		return err
+	})
+	return res, err
+}
+
+func (s *ArticleStorage) CountArticles(ctx context.Context, params storage.ArticleFilter, mode storage.CountMode) (int64, error) {
+	var res int64
+	err := s.breaker.Do(func() error {
+		var err error
+		res, err = s.next.CountArticles(ctx, params, mode)
+		return err
+	})
+	return res, err
+}
+
+func (s *ArticleStorage) GetArticle(ctx context.Context, slug string) (storage.Article, error) {
+	var res storage.Article
+	err := s.breaker.Do(func() error {
+		var err error
+		res, err = s.next.GetArticle(ctx, slug)
+		return err
+	})
+	return res, err
+}
+
+func (s *ArticleStorage) CreateArticle(ctx context.Context, article storage.Article) error {
+	return s.breaker.Do(func() error {
+		return s.next.CreateArticle(ctx, article)
+	})
+}
+
+func (s *ArticleStorage) StoreArticles(ctx context.Context, articles []storage.Article) error {
+	return s.breaker.Do(func() error {
+		return s.next.StoreArticles(ctx, articles)
+	})
+}
+
+func (s *ArticleStorage) UpdateArticle(ctx context.Context, slug string, article storage.Article) error {
+	return s.breaker.Do(func() error {
+		return s.next.UpdateArticle(ctx, slug, article)
+	})
+}
+
+func (s *ArticleStorage) DeleteArticles(ctx context.Context, articles []storage.Article) error {
+	return s.breaker.Do(func() error {
+		return s.next.DeleteArticles(ctx, articles)
+	})
+}
+
+func (s *ArticleStorage) UniqueArticleSlug(ctx context.Context, base string) (string, error) {
+	var res string
+	err := s.breaker.Do(func() error {
+		var err error
+		res, err = s.next.UniqueArticleSlug(ctx, base)
+		return err
+	})
+	return res, err
+}
@@ -0,0 +1,44 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/agalitsyn/go-app/internal/pkg/breaker"
+	"github.com/agalitsyn/go-app/internal/storage"
+)
+
+type stubArticleStorage struct {
+	storage.ArticleRepository
+	err error
+}
+
+func (s *stubArticleStorage) GetArticle(ctx context.Context, slug string) (storage.Article, error) {
+	return storage.Article{}, s.err
+}
+
+func TestArticleStorage(t *testing.T) {
+	t.Parallel()
+
+	stub := &stubArticleStorage{err: storage.ErrArticleNotFound}
+	b := breaker.New(breaker.Options{FailureRatio: 0.5, MinRequests: 2, Window: time.Minute, OpenTimeout: time.Minute, IsFailure: IsFailure})
+	s := NewArticleStorage(stub, b)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := s.GetArticle(ctx, "foo")
+		assert.ErrorIs(t, err, storage.ErrArticleNotFound)
+	}
+	assert.Equal(t, breaker.Closed, b.State(), "not found is not a failure")
+
+	stub.err = errors.New("connection refused")
+	for i := 0; i < 3; i++ {
+		s.GetArticle(ctx, "foo")
+	}
+	_, err := s.GetArticle(ctx, "foo")
+	assert.ErrorIs(t, err, breaker.ErrOpen)
+}
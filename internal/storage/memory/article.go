@@ -0,0 +1,303 @@
+// Package memory implements storages in process memory for demos and tests, data is lost on exit.
+// Behavior follows rdb storages, including lowercased slugs, upserts and slug history.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/agalitsyn/go-app/internal/pkg/slug"
+	"github.com/agalitsyn/go-app/internal/storage"
+)
+
+// ArticleStorage is safe for concurrent use. Authors can't be stored, so articles have no author.
+type ArticleStorage struct {
+	mu       sync.RWMutex
+	lastID   int
+	articles map[int]storage.Article
+	// tags of articles are sorted and unique.
+	tags map[int][]string
+	// slugs map current slugs to article ids.
+	slugs map[string]int
+	// history maps previous slugs to article ids.
+	history map[string]int
+}
+
+func NewArticleStorage() *ArticleStorage {
+	return &ArticleStorage{
+		articles: make(map[int]storage.Article),
+		tags:     make(map[int][]string),
+		slugs:    make(map[string]int),
+		history:  make(map[string]int),
+	}
+}
+
+func (s *ArticleStorage) FilterArticles(ctx context.Context, params storage.ArticleFilter) ([]storage.Article, error) {
+	fields, err := articleSelectFields(params)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched, err := s.match(params)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Offset >= uint64(len(matched)) {
+		return []storage.Article{}, nil
+	}
+	matched = matched[params.Offset:]
+	if params.Limit > 0 && params.Limit < uint64(len(matched)) {
+		matched = matched[:params.Limit]
+	}
+
+	res := make([]storage.Article, 0, len(matched))
+	for _, id := range matched {
+		article := project(s.articles[id], fields)
+		if params.WithTags {
+			article.Tags = copyTags(s.tags[id])
+		}
+		res = append(res, article)
+	}
+	return res, nil
+}
+
+// match returns sorted ids of articles matching filter expression.
+func (s *ArticleStorage) match(params storage.ArticleFilter) ([]int, error) {
+	res := make([]int, 0, len(s.articles))
+	for id, article := range s.articles {
+		if params.Expr != nil {
+			ok, err := matchArticle(params.Expr, article, s.tags[id])
+			if err != nil {
+				return nil, fmt.Errorf("could not match filter: %w", err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		res = append(res, id)
+	}
+	sort.Ints(res)
+	return res, nil
+}
+
+// articleSelectFields returns requested fields, ID is always selected and author_id is required for loading authors.
+func articleSelectFields(params storage.ArticleFilter) (map[string]bool, error) {
+	res := make(map[string]bool, len(storage.ArticleFields))
+	if len(params.Fields) == 0 {
+		for _, f := range storage.ArticleFields {
+			res[f] = true
+		}
+		return res, nil
+	}
+
+	res[storage.ArticleFieldID] = true
+	res[storage.ArticleFieldAuthorID] = params.WithAuthor
+	for _, f := range params.Fields {
+		known := false
+		for _, field := range storage.ArticleFields {
+			known = known || f == field
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown article field: %s", f)
+		}
+		res[f] = true
+	}
+	return res, nil
+}
+
+func project(article storage.Article, fields map[string]bool) storage.Article {
+	res := storage.Article{ID: article.ID}
+	if fields[storage.ArticleFieldTitle] {
+		res.Title = article.Title
+	}
+	if fields[storage.ArticleFieldSlug] {
+		res.Slug = article.Slug
+	}
+	if fields[storage.ArticleFieldAuthorID] {
+		res.AuthorID = article.AuthorID
+	}
+	return res
+}
+
+// CountArticles is always exact.
+func (s *ArticleStorage) CountArticles(ctx context.Context, params storage.ArticleFilter, mode storage.CountMode) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched, err := s.match(params)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(matched)), nil
+}
+
+func (s *ArticleStorage) GetArticle(ctx context.Context, slug string) (storage.Article, error) {
+	slug = strings.ToLower(slug)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if id, ok := s.slugs[slug]; ok {
+		return s.articles[id], nil
+	}
+	if id, ok := s.history[slug]; ok {
+		return s.articles[id], nil
+	}
+	return storage.Article{}, storage.ErrArticleNotFound
+}
+
+func (s *ArticleStorage) CreateArticle(ctx context.Context, article storage.Article) error {
+	slug := strings.ToLower(article.Slug)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, taken := s.slugs[slug]; taken {
+		return storage.ErrArticleConflict
+	}
+	s.lastID++
+	id := s.lastID
+	s.slugs[slug] = id
+	s.articles[id] = storage.Article{ID: id, Title: article.Title, Slug: slug}
+	if article.Tags != nil {
+		s.tags[id] = uniqueTags(article.Tags)
+	}
+	return nil
+}
+
+func (s *ArticleStorage) StoreArticles(ctx context.Context, articles []storage.Article) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Articles are stored in order of slugs like rdb does, so new ones get ids in the same order.
+	unique := make(map[string]storage.Article, len(articles))
+	ordered := make([]string, 0, len(articles))
+	for _, obj := range articles {
+		slug := strings.ToLower(obj.Slug)
+		if _, visited := unique[slug]; slug == "" || visited {
+			continue
+		}
+		unique[slug] = obj
+		ordered = append(ordered, slug)
+	}
+	sort.Strings(ordered)
+
+	for _, slug := range ordered {
+		obj := unique[slug]
+		id, ok := s.slugs[slug]
+		if !ok {
+			s.lastID++
+			id = s.lastID
+			s.slugs[slug] = id
+		}
+		article := s.articles[id]
+		article.ID = id
+		article.Title = obj.Title
+		article.Slug = slug
+		s.articles[id] = article
+
+		// Nil tags are kept as is.
+		if obj.Tags != nil {
+			s.tags[id] = uniqueTags(obj.Tags)
+		}
+	}
+	return nil
+}
+
+func (s *ArticleStorage) UpdateArticle(ctx context.Context, slug string, article storage.Article) error {
+	oldSlug := strings.ToLower(slug)
+	newSlug := strings.ToLower(article.Slug)
+	if newSlug == "" {
+		newSlug = oldSlug
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.slugs[oldSlug]
+	if !ok {
+		return storage.ErrArticleNotFound
+	}
+	if newSlug != oldSlug {
+		if _, taken := s.slugs[newSlug]; taken {
+			return storage.ErrArticleConflict
+		}
+		// Old slug goes to history and new slug is removed from there,
+		// so GetArticle always resolves current slug first.
+		delete(s.slugs, oldSlug)
+		s.slugs[newSlug] = id
+		s.history[oldSlug] = id
+		delete(s.history, newSlug)
+	}
+
+	stored := s.articles[id]
+	stored.Title = article.Title
+	stored.Slug = newSlug
+	s.articles[id] = stored
+	return nil
+}
+
+func (s *ArticleStorage) UniqueArticleSlug(ctx context.Context, base string) (string, error) {
+	base = strings.ToLower(base)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Slugs from history are taken as well, otherwise old links will point to the new article.
+	return slug.Unique(base, func(candidate string) bool {
+		_, current := s.slugs[candidate]
+		_, previous := s.history[candidate]
+		return current || previous
+	}), nil
+}
+
+func (s *ArticleStorage) DeleteArticles(ctx context.Context, articles []storage.Article) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range articles {
+		slug := strings.ToLower(articles[i].Slug)
+		id, ok := s.slugs[slug]
+		if !ok {
+			continue
+		}
+
+		delete(s.slugs, slug)
+		delete(s.articles, id)
+		delete(s.tags, id)
+		for previous, articleID := range s.history {
+			if articleID == id {
+				delete(s.history, previous)
+			}
+		}
+	}
+	return nil
+}
+
+func uniqueTags(tags []string) []string {
+	res := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if !seen[tag] {
+			seen[tag] = true
+			res = append(res, tag)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// copyTags returns nil for articles without tags like rdb does.
+func copyTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	return append([]string(nil), tags...)
+}
@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agalitsyn/go-app/internal/storage"
+	"github.com/agalitsyn/go-app/internal/storage/storagetest"
+)
+
+func TestArticleStorage_UpdateArticle(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := NewArticleStorage()
+	require.NoError(t, store.StoreArticles(ctx, []storage.Article{
+		{Title: "Foo", Slug: "foo"},
+		{Title: "Bar", Slug: "bar"},
+	}))
+	foo, err := store.GetArticle(ctx, "foo")
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, store.UpdateArticle(ctx, "foo", storage.Article{Title: "Foo", Slug: "bar"}), storage.ErrArticleConflict)
+	assert.ErrorIs(t, store.UpdateArticle(ctx, "baz", storage.Article{Title: "Baz"}), storage.ErrArticleNotFound)
+
+	require.NoError(t, store.UpdateArticle(ctx, "Foo", storage.Article{Title: "New Foo", Slug: "New-Foo"}))
+	article, err := store.GetArticle(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, storage.Article{ID: foo.ID, Title: "New Foo", Slug: "new-foo"}, article, "found by previous slug")
+
+	slug, err := store.UniqueArticleSlug(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo-2", slug, "previous slugs are taken")
+
+	require.NoError(t, store.DeleteArticles(ctx, []storage.Article{{Slug: "new-foo"}}))
+	_, err = store.GetArticle(ctx, "foo")
+	assert.ErrorIs(t, err, storage.ErrArticleNotFound, "history is deleted with article")
+}
+
+func TestArticleStorage_FilterArticles(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := NewArticleStorage()
+	require.NoError(t, store.StoreArticles(ctx, []storage.Article{
+		{Title: "Foo", Slug: "foo", Tags: []string{"go", "books", "go"}},
+		{Title: "Bar", Slug: "bar"},
+		{Title: "Baz", Slug: "baz"},
+	}))
+
+	articles, err := store.FilterArticles(ctx, storage.ArticleFilter{Fields: []string{storage.ArticleFieldSlug}, WithTags: true, Offset: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []storage.Article{
+		{ID: 2, Slug: "baz"},
+		{ID: 3, Slug: "foo", Tags: []string{"books", "go"}},
+	}, articles)
+
+	_, err = store.FilterArticles(ctx, storage.ArticleFilter{Fields: []string{"password"}})
+	assert.Error(t, err)
+}
+
+func TestArticleStorage_conformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestArticleRepository(t, func(t *testing.T) storage.ArticleRepository {
+		return NewArticleStorage()
+	})
+}
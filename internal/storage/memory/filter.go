@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/agalitsyn/go-app/internal/pkg/filter"
+	"github.com/agalitsyn/go-app/internal/storage"
+)
+
+// matchArticle evaluates filter expression against article with its tags.
+// Expression must be validated against storage.ArticleFilterSchema before.
+func matchArticle(e filter.Expr, article storage.Article, tags []string) (bool, error) {
+	switch e := e.(type) {
+	case *filter.Logical:
+		left, err := matchArticle(e.Left, article, tags)
+		if err != nil {
+			return false, err
+		}
+		right, err := matchArticle(e.Right, article, tags)
+		if err != nil {
+			return false, err
+		}
+		if e.Op == filter.Or {
+			return left || right, nil
+		}
+		return left && right, nil
+	case *filter.Not:
+		x, err := matchArticle(e.X, article, tags)
+		return !x, err
+	case *filter.Comparison:
+		return matchArticleComparison(e, article, tags)
+	default:
+		return false, fmt.Errorf("unknown filter expression %T", e)
+	}
+}
+
+func matchArticleComparison(c *filter.Comparison, article storage.Article, tags []string) (bool, error) {
+	switch c.Field {
+	case storage.ArticleFieldID:
+		return compare(int64(article.ID), c)
+	case storage.ArticleFieldTitle:
+		return compare(article.Title, c)
+	case storage.ArticleFieldSlug:
+		return compare(article.Slug, c)
+	case storage.ArticleFilterFieldTag:
+		// Negative condition means that article has no such tag at all.
+		op, exists := c.Op, true
+		if c.Op == filter.OpNotEq {
+			op, exists = filter.OpEq, false
+		}
+		positive := &filter.Comparison{Field: c.Field, Op: op, Values: c.Values}
+		for _, tag := range tags {
+			ok, err := compare(tag, positive)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return exists, nil
+			}
+		}
+		return !exists, nil
+	default:
+		return false, fmt.Errorf("unknown filter field: %s", c.Field)
+	}
+}
+
+// compare applies comparison to value, which is int64 or string like values of the comparison.
+func compare(v interface{}, c *filter.Comparison) (bool, error) {
+	if len(c.Values) == 0 {
+		return false, fmt.Errorf("no values for filter field: %s", c.Field)
+	}
+
+	switch c.Op {
+	case filter.OpEq:
+		return v == c.Values[0], nil
+	case filter.OpNotEq:
+		return v != c.Values[0], nil
+	case filter.OpGt, filter.OpGtOrEq, filter.OpLt, filter.OpLtOrEq:
+		a, aok := v.(int64)
+		b, bok := c.Values[0].(int64)
+		if !aok || !bok {
+			return false, fmt.Errorf("filter field %s: %s expects integer", c.Field, c.Op)
+		}
+		switch c.Op {
+		case filter.OpGt:
+			return a > b, nil
+		case filter.OpGtOrEq:
+			return a >= b, nil
+		case filter.OpLt:
+			return a < b, nil
+		default:
+			return a <= b, nil
+		}
+	case filter.OpContains:
+		a, aok := v.(string)
+		b, bok := c.Values[0].(string)
+		if !aok || !bok {
+			return false, fmt.Errorf("filter field %s: contains expects string", c.Field)
+		}
+		return strings.Contains(strings.ToLower(a), strings.ToLower(b)), nil
+	case filter.OpIn:
+		for _, value := range c.Values {
+			if v == value {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown filter operator: %s", c.Op)
+	}
+}
@@ -0,0 +1,44 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agalitsyn/go-app/internal/pkg/filter"
+	"github.com/agalitsyn/go-app/internal/storage"
+)
+
+func TestMatchArticle(t *testing.T) {
+	t.Parallel()
+
+	article := storage.Article{ID: 150, Title: "Go in 50% of time", Slug: "go"}
+	tags := []string{"a", "c"}
+
+	tests := []struct {
+		input string
+		match bool
+	}{
+		{input: `title~"50%" and id>100`, match: true},
+		{input: `title~"GO IN"`, match: true},
+		{input: `id<=100`, match: false},
+		{input: `not slug = "foo" and tag in ("a","b")`, match: true},
+		{input: `tag = "b"`, match: false},
+		{input: `tag != "a"`, match: false},
+		{input: `tag != "b"`, match: true},
+		{input: `tag ~ "C"`, match: true},
+		{input: `slug = "Go" or id in (1, 150)`, match: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			e, err := filter.ParseWithSchema(tt.input, storage.ArticleFilterSchema)
+			require.NoError(t, err)
+
+			ok, err := matchArticle(e, article, tags)
+			require.NoError(t, err)
+			assert.Equal(t, tt.match, ok)
+		})
+	}
+}
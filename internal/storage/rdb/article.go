@@ -2,16 +2,24 @@ package rdb
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
 
 	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
 
 	"github.com/agalitsyn/go-app/internal/pkg/postgres"
+	"github.com/agalitsyn/go-app/internal/pkg/slug"
 	"github.com/agalitsyn/go-app/internal/storage"
+	"github.com/agalitsyn/go-app/internal/storage/sqlfilter"
 )
 
+// ArticleChangedChannel receives notification on every change of articles and related tables.
+const ArticleChangedChannel = "article_changed"
+
 type ArticleStorage struct {
 	db *postgres.DB
 }
@@ -21,16 +29,34 @@ func NewArticleStorage(db *postgres.DB) *ArticleStorage {
 }
 
 func (s *ArticleStorage) FilterArticles(ctx context.Context, params storage.ArticleFilter) ([]storage.Article, error) {
-	qb := squirrel.Select("id", "title", "slug").
+	fields, err := articleSelectFields(params)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, 0, len(fields))
+	for _, f := range fields {
+		columns = append(columns, articleColumns[f])
+	}
+	where, err := articleConditions(params)
+	if err != nil {
+		return nil, err
+	}
+
+	qb := squirrel.Select(columns...).
 		From("article").
+		Where(where).
 		OrderBy("id ASC")
 
 	if params.Limit > 0 {
 		qb = qb.Limit(params.Limit)
 	}
+	if params.Offset > 0 {
+		qb = qb.Offset(params.Offset)
+	}
 
 	query, args := qb.PlaceholderFormat(squirrel.Dollar).MustSql()
-	rows, err := s.db.Session.Query(ctx, query, args...)
+	rows, err := s.db.Read(ctx).Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("could not perform query: %w", err)
 	}
@@ -39,12 +65,11 @@ func (s *ArticleStorage) FilterArticles(ctx context.Context, params storage.Arti
 	res := make([]storage.Article, 0, params.Limit)
 	for rows.Next() {
 		var article storage.Article
-		err = rows.Scan(
-			&article.ID,
-			&article.Title,
-			&article.Slug,
-		)
-		if err != nil {
+		dest := make([]interface{}, 0, len(fields))
+		for _, f := range fields {
+			dest = append(dest, articleScanDest(&article, f))
+		}
+		if err = rows.Scan(dest...); err != nil {
 			return nil, fmt.Errorf("could not scan row: %w", err)
 		}
 		res = append(res, article)
@@ -53,9 +78,330 @@ func (s *ArticleStorage) FilterArticles(ctx context.Context, params storage.Arti
 		return nil, fmt.Errorf("could not iterate rows: %w", err)
 	}
 
+	if params.WithAuthor {
+		if err = s.loadAuthors(ctx, res); err != nil {
+			return nil, err
+		}
+	}
+	if params.WithTags {
+		if err = s.loadTags(ctx, res); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// articleConditions builds WHERE clause from filter params, nil means no conditions.
+func articleConditions(params storage.ArticleFilter) (squirrel.Sqlizer, error) {
+	if params.Expr == nil {
+		return nil, nil
+	}
+	where, err := compileArticleFilter(params.Expr)
+	if err != nil {
+		return nil, fmt.Errorf("could not compile filter: %w", err)
+	}
+	return where, nil
+}
+
+var articleColumns = map[string]string{
+	storage.ArticleFieldID:       "id",
+	storage.ArticleFieldTitle:    "title",
+	storage.ArticleFieldSlug:     "slug",
+	storage.ArticleFieldAuthorID: "COALESCE(author_id, 0)",
+}
+
+// articleSelectFields returns fields to select in stable order.
+// ID is always selected and author_id is required for loading authors.
+func articleSelectFields(params storage.ArticleFilter) ([]string, error) {
+	if len(params.Fields) == 0 {
+		return storage.ArticleFields, nil
+	}
+
+	requested := map[string]bool{
+		storage.ArticleFieldID:       true,
+		storage.ArticleFieldAuthorID: params.WithAuthor,
+	}
+	for _, f := range params.Fields {
+		if _, ok := articleColumns[f]; !ok {
+			return nil, fmt.Errorf("unknown article field: %s", f)
+		}
+		requested[f] = true
+	}
+
+	var res []string
+	for _, f := range storage.ArticleFields {
+		if requested[f] {
+			res = append(res, f)
+		}
+	}
 	return res, nil
 }
 
+func articleScanDest(article *storage.Article, field string) interface{} {
+	switch field {
+	case storage.ArticleFieldTitle:
+		return &article.Title
+	case storage.ArticleFieldSlug:
+		return &article.Slug
+	case storage.ArticleFieldAuthorID:
+		return &article.AuthorID
+	default:
+		return &article.ID
+	}
+}
+
+// loadAuthors fetches authors of all articles with one query.
+func (s *ArticleStorage) loadAuthors(ctx context.Context, articles []storage.Article) error {
+	var ids []int
+	for i := range articles {
+		if articles[i].AuthorID != 0 {
+			ids = append(ids, articles[i].AuthorID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// language=PostgreSQL
+	const query = `SELECT id, name FROM author WHERE id = ANY($1);`
+	rows, err := s.db.Read(ctx).Query(ctx, query, ids)
+	if err != nil {
+		return fmt.Errorf("could not perform query: %w", err)
+	}
+	defer rows.Close()
+
+	authors := make(map[int]*storage.Author, len(ids))
+	for rows.Next() {
+		var author storage.Author
+		if err = rows.Scan(&author.ID, &author.Name); err != nil {
+			return fmt.Errorf("could not scan row: %w", err)
+		}
+		authors[author.ID] = &author
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("could not iterate rows: %w", err)
+	}
+
+	for i := range articles {
+		articles[i].Author = authors[articles[i].AuthorID]
+	}
+	return nil
+}
+
+// loadTags fetches tags of all articles with one query.
+func (s *ArticleStorage) loadTags(ctx context.Context, articles []storage.Article) error {
+	if len(articles) == 0 {
+		return nil
+	}
+
+	ids := make([]int, 0, len(articles))
+	for i := range articles {
+		ids = append(ids, articles[i].ID)
+	}
+
+	// language=PostgreSQL
+	const query = `SELECT article_id, tag FROM article_tag WHERE article_id = ANY($1) ORDER BY tag;`
+	rows, err := s.db.Read(ctx).Query(ctx, query, ids)
+	if err != nil {
+		return fmt.Errorf("could not perform query: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[int][]string, len(ids))
+	for rows.Next() {
+		var (
+			id  int
+			tag string
+		)
+		if err = rows.Scan(&id, &tag); err != nil {
+			return fmt.Errorf("could not scan row: %w", err)
+		}
+		tags[id] = append(tags[id], tag)
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("could not iterate rows: %w", err)
+	}
+
+	for i := range articles {
+		articles[i].Tags = tags[articles[i].ID]
+	}
+	return nil
+}
+
+// Planner estimates are inaccurate for small or recently changed tables,
+// so below this number of rows exact count is used anyway.
+const estimateCountThreshold = 10000
+
+func (s *ArticleStorage) CountArticles(ctx context.Context, params storage.ArticleFilter, mode storage.CountMode) (int64, error) {
+	where, err := articleConditions(params)
+	if err != nil {
+		return 0, err
+	}
+
+	qb := squirrel.Select("COUNT(*)").From("article").Where(where)
+
+	if mode == storage.CountEstimate {
+		estimate, err := s.estimateRows(ctx, squirrel.Select("id").From("article").Where(where))
+		if err != nil {
+			return 0, err
+		}
+		if estimate >= estimateCountThreshold {
+			return estimate, nil
+		}
+	}
+
+	query, args := qb.PlaceholderFormat(squirrel.Dollar).MustSql()
+	var count int64
+	if err := s.db.Read(ctx).QueryRow(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("could not perform query: %w", err)
+	}
+	return count, nil
+}
+
+func (s *ArticleStorage) estimateRows(ctx context.Context, qb squirrel.SelectBuilder) (int64, error) {
+	query, args := qb.PlaceholderFormat(squirrel.Dollar).MustSql()
+
+	var plan []byte
+	if err := s.db.Read(ctx).QueryRow(ctx, "EXPLAIN (FORMAT JSON) "+query, args...).Scan(&plan); err != nil {
+		return 0, fmt.Errorf("could not explain query: %w", err)
+	}
+
+	var res []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(plan, &res); err != nil {
+		return 0, fmt.Errorf("could not decode query plan: %w", err)
+	}
+	if len(res) == 0 {
+		return 0, errors.New("empty query plan")
+	}
+	return int64(res[0].Plan.Rows), nil
+}
+
+func (s *ArticleStorage) GetArticle(ctx context.Context, slug string) (storage.Article, error) {
+	// language=PostgreSQL
+	const query = `
+		SELECT id, title, slug, COALESCE(author_id, 0), 0 AS priority
+		FROM article
+		WHERE slug = $1
+		UNION ALL
+		SELECT a.id, a.title, a.slug, COALESCE(a.author_id, 0), 1 AS priority
+		FROM slug_history h
+		JOIN article a ON a.id = h.article_id
+		WHERE h.slug = $1
+		ORDER BY priority
+		LIMIT 1;
+	`
+	var (
+		article  storage.Article
+		priority int
+	)
+	// Article is usually requested right after create or rename, so primary is queried.
+	err := s.db.Primary(ctx).QueryRow(ctx, query, strings.ToLower(slug)).Scan(
+		&article.ID,
+		&article.Title,
+		&article.Slug,
+		&article.AuthorID,
+		&priority,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.Article{}, storage.ErrArticleNotFound
+	}
+	if err != nil {
+		return storage.Article{}, fmt.Errorf("could not perform query: %w", err)
+	}
+	return article, nil
+}
+
+func (s *ArticleStorage) UpdateArticle(ctx context.Context, slug string, article storage.Article) error {
+	oldSlug := strings.ToLower(slug)
+	newSlug := strings.ToLower(article.Slug)
+	if newSlug == "" {
+		newSlug = oldSlug
+	}
+
+	// Old slug goes to history and new slug is removed from there,
+	// so GetArticle always resolves current slug first.
+	// language=PostgreSQL
+	const query = `
+		WITH updated AS (
+			UPDATE article SET title = $2, slug = $3 WHERE slug = $1 RETURNING id
+		), history AS (
+			INSERT INTO slug_history (slug, article_id)
+			SELECT $1, id FROM updated WHERE $1 <> $3
+			ON CONFLICT (slug) DO UPDATE SET article_id = excluded.article_id
+		), cleanup AS (
+			DELETE FROM slug_history WHERE slug = $3 AND EXISTS (SELECT 1 FROM updated)
+		)
+		SELECT COUNT(*) FROM updated;
+	`
+	var updated int
+	err := s.db.Write(ctx).QueryRow(ctx, query, oldSlug, article.Title, newSlug).Scan(&updated)
+	if isUniqueViolation(err) {
+		return storage.ErrArticleConflict
+	}
+	if err != nil {
+		return fmt.Errorf("could not perform query: %w", err)
+	}
+	if updated == 0 {
+		return storage.ErrArticleNotFound
+	}
+	return nil
+}
+
+func (s *ArticleStorage) UniqueArticleSlug(ctx context.Context, base string) (string, error) {
+	base = strings.ToLower(base)
+
+	// Slugs from history are taken as well, otherwise old links will point to the new article.
+	// language=PostgreSQL
+	const query = `
+		SELECT slug FROM article WHERE slug = $1 OR slug LIKE $2
+		UNION
+		SELECT slug FROM slug_history WHERE slug = $1 OR slug LIKE $2;
+	`
+	// Slug taken by a recent write may be not replicated yet, so primary is queried.
+	rows, err := s.db.Primary(ctx).Query(ctx, query, base, sqlfilter.EscapeLike(base)+"-%")
+	if err != nil {
+		return "", fmt.Errorf("could not perform query: %w", err)
+	}
+	defer rows.Close()
+
+	taken := make(map[string]bool)
+	for rows.Next() {
+		var existing string
+		if err = rows.Scan(&existing); err != nil {
+			return "", fmt.Errorf("could not scan row: %w", err)
+		}
+		taken[existing] = true
+	}
+	if err = rows.Err(); err != nil {
+		return "", fmt.Errorf("could not iterate rows: %w", err)
+	}
+
+	return slug.Unique(base, func(candidate string) bool { return taken[candidate] }), nil
+}
+
+func (s *ArticleStorage) CreateArticle(ctx context.Context, article storage.Article) error {
+	// language=PostgreSQL
+	const query = `INSERT INTO article (title, slug) VALUES ($1, $2) RETURNING id;`
+
+	// Article and its tags are stored atomically.
+	return s.db.Tx().InTx(ctx, func(ctx context.Context) error {
+		var id int
+		err := s.db.Write(ctx).QueryRow(ctx, query, article.Title, strings.ToLower(article.Slug)).Scan(&id)
+		if isUniqueViolation(err) {
+			return storage.ErrArticleConflict
+		}
+		if err != nil {
+			return fmt.Errorf("could not perform query: %w", err)
+		}
+		return s.replaceTags(ctx, id, article.Tags)
+	})
+}
+
 func (s *ArticleStorage) StoreArticles(ctx context.Context, articles []storage.Article) error {
 	if len(articles) == 0 {
 		return nil
@@ -89,17 +435,67 @@ func (s *ArticleStorage) StoreArticles(ctx context.Context, articles []storage.A
 		title = excluded.title,
 		slug = excluded.slug
 	`
-	qb = qb.Suffix(onConflict)
+	qb = qb.Suffix(onConflict + " RETURNING id, slug")
 
 	query, args, err := qb.PlaceholderFormat(squirrel.Dollar).ToSql()
 	if err != nil {
 		return fmt.Errorf("could not build query: %w", err)
 	}
 
-	if _, err = s.db.Session.Exec(ctx, query, args...); err != nil {
-		return fmt.Errorf("could not perform query: %w", err)
+	// Articles and their tags are stored atomically.
+	return s.db.Tx().InTx(ctx, func(ctx context.Context) error {
+		rows, err := s.db.Write(ctx).Query(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("could not perform query: %w", err)
+		}
+		defer rows.Close()
+
+		ids := make(map[string]int, len(ordered))
+		for rows.Next() {
+			var (
+				id   int
+				slug string
+			)
+			if err = rows.Scan(&id, &slug); err != nil {
+				return fmt.Errorf("could not scan row: %w", err)
+			}
+			ids[slug] = id
+		}
+		if err = rows.Err(); err != nil {
+			return fmt.Errorf("could not iterate rows: %w", err)
+		}
+		rows.Close()
+
+		for _, slug := range ordered {
+			if err = s.replaceTags(ctx, ids[slug], unique[slug].Tags); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// replaceTags sets tags of article, nil tags are kept as is.
+func (s *ArticleStorage) replaceTags(ctx context.Context, id int, tags []string) error {
+	if tags == nil {
+		return nil
+	}
+
+	// language=PostgreSQL
+	const deleteQuery = `DELETE FROM article_tag WHERE article_id = $1 AND tag <> ALL($2);`
+	if _, err := s.db.Write(ctx).Exec(ctx, deleteQuery, id, tags); err != nil {
+		return fmt.Errorf("could not delete tags: %w", err)
 	}
 
+	// language=PostgreSQL
+	const insertQuery = `
+		INSERT INTO article_tag (article_id, tag)
+		SELECT $1, tag FROM unnest($2::text[]) AS tag
+		ON CONFLICT DO NOTHING;
+	`
+	if _, err := s.db.Write(ctx).Exec(ctx, insertQuery, id, tags); err != nil {
+		return fmt.Errorf("could not store tags: %w", err)
+	}
 	return nil
 }
 
@@ -117,7 +513,7 @@ func (s *ArticleStorage) DeleteArticles(ctx context.Context, articles []storage.
 
 	// language=PostgreSQL
 	const query = `DELETE FROM article WHERE slug = ANY($1);`
-	_, err := s.db.Session.Exec(ctx, query, slugs)
+	_, err := s.db.Write(ctx).Exec(ctx, query, slugs)
 	if err != nil {
 		return fmt.Errorf("could not perform query: %w", err)
 	}
@@ -2,13 +2,16 @@ package rdb
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/agalitsyn/go-app/internal/pkg/postgres"
 	"github.com/agalitsyn/go-app/internal/storage"
+	"github.com/agalitsyn/go-app/internal/storage/storagetest"
 )
 
 func TestArticleStorage_DeleteArticles(t *testing.T) {
@@ -88,6 +91,103 @@ func TestArticleStorage_FilterArticles(t *testing.T) {
 
 		assert.Equal(t, 2, len(articles))
 	})
+
+	t.Run("fields", func(t *testing.T) {
+		articles, err := store.FilterArticles(ctx, storage.ArticleFilter{
+			Fields: []string{storage.ArticleFieldSlug},
+		})
+		require.NoError(t, err)
+		require.Greater(t, len(articles), 0)
+
+		assert.Equal(t, 1, articles[0].ID)
+		assert.Equal(t, "foo", articles[0].Slug)
+		assert.Empty(t, articles[0].Title)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		_, err := store.FilterArticles(ctx, storage.ArticleFilter{
+			Fields: []string{"password"},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestArticleStorage_FilterArticles_include(t *testing.T) {
+	t.Parallel()
+
+	db, teardown := setupTestDB(t)
+	defer teardown()
+
+	err := migrateArticle(db)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	store := NewArticleStorage(db)
+
+	// language=PostgreSQL
+	const fixtures = `
+		INSERT INTO author ( id, name ) VALUES ( 1, 'John' );
+		INSERT INTO article ( id, title, slug, author_id ) VALUES ( 1, 'Foo', 'foo', 1 ), ( 2, 'Bar', 'bar', NULL );
+		INSERT INTO article_tag ( article_id, tag ) VALUES ( 1, 'go' ), ( 1, 'api' );
+	`
+	_, err = db.Session.Exec(ctx, fixtures)
+	require.NoError(t, err)
+
+	articles, err := store.FilterArticles(ctx, storage.ArticleFilter{
+		Fields:     []string{storage.ArticleFieldTitle},
+		WithAuthor: true,
+		WithTags:   true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(articles))
+
+	require.NotNil(t, articles[0].Author)
+	assert.Equal(t, "John", articles[0].Author.Name)
+	assert.Equal(t, []string{"api", "go"}, articles[0].Tags)
+
+	assert.Nil(t, articles[1].Author)
+	assert.Empty(t, articles[1].Tags)
+}
+
+func TestArticleStorage_CountArticles(t *testing.T) {
+	t.Parallel()
+
+	db, teardown := setupTestDB(t)
+	defer teardown()
+
+	err := migrateArticle(db)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	store := NewArticleStorage(db)
+
+	err = loadArticles(store, []storage.Article{
+		{
+			ID:    1,
+			Title: "Foo",
+			Slug:  "foo",
+		},
+		{
+			ID:    2,
+			Title: "Bar",
+			Slug:  "bar",
+		},
+	})
+	require.NoError(t, err)
+
+	t.Run("exact", func(t *testing.T) {
+		c, err := store.CountArticles(ctx, storage.ArticleFilter{Limit: 1}, storage.CountExact)
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(2), c)
+	})
+
+	t.Run("estimate on small table", func(t *testing.T) {
+		c, err := store.CountArticles(ctx, storage.ArticleFilter{}, storage.CountEstimate)
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(2), c)
+	})
 }
 
 func TestArticleStorage_StoreArticles(t *testing.T) {
@@ -140,6 +240,207 @@ func TestArticleStorage_StoreArticles(t *testing.T) {
 
 		assert.Equal(t, 2, c)
 	})
+
+	t.Run("tags", func(t *testing.T) {
+		err := store.StoreArticles(ctx, []storage.Article{
+			{Title: "Tagged", Slug: "tagged", Tags: []string{"go", "books"}},
+		})
+		require.NoError(t, err)
+
+		// Nil tags are kept, empty tags are removed.
+		err = store.StoreArticles(ctx, []storage.Article{{Title: "Tagged", Slug: "tagged"}})
+		require.NoError(t, err)
+		err = store.StoreArticles(ctx, []storage.Article{{Title: "Tagged", Slug: "foo", Tags: []string{}}})
+		require.NoError(t, err)
+
+		articles, err := store.FilterArticles(ctx, storage.ArticleFilter{WithTags: true})
+		require.NoError(t, err)
+		tags := make(map[string][]string)
+		for _, article := range articles {
+			tags[article.Slug] = article.Tags
+		}
+		assert.Equal(t, []string{"books", "go"}, tags["tagged"])
+		assert.Empty(t, tags["foo"])
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		errFailed := errors.New("failed")
+		err := db.Tx().InTx(ctx, func(ctx context.Context) error {
+			if err := store.StoreArticles(ctx, []storage.Article{{Title: "Rollback", Slug: "rollback"}}); err != nil {
+				return err
+			}
+			return errFailed
+		})
+		assert.ErrorIs(t, err, errFailed)
+
+		_, err = store.GetArticle(ctx, "rollback")
+		assert.ErrorIs(t, err, storage.ErrArticleNotFound)
+	})
+}
+
+func TestArticleStorage_UpdateArticle(t *testing.T) {
+	t.Parallel()
+
+	db, teardown := setupTestDB(t)
+	defer teardown()
+
+	err := migrateArticle(db)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	store := NewArticleStorage(db)
+
+	err = loadArticles(store, []storage.Article{
+		{
+			ID:    1,
+			Title: "Foo",
+			Slug:  "foo",
+		},
+		{
+			ID:    2,
+			Title: "Bar",
+			Slug:  "bar",
+		},
+	})
+	require.NoError(t, err)
+
+	t.Run("rename", func(t *testing.T) {
+		err := store.UpdateArticle(ctx, "foo", storage.Article{Title: "Foo", Slug: "new-foo"})
+		require.NoError(t, err)
+
+		article, err := store.GetArticle(ctx, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, "new-foo", article.Slug)
+
+		slug, err := store.UniqueArticleSlug(ctx, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, "foo-2", slug)
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		err := store.UpdateArticle(ctx, "bar", storage.Article{Title: "Bar", Slug: "new-foo"})
+		assert.Equal(t, storage.ErrArticleConflict, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		err := store.UpdateArticle(ctx, "baz", storage.Article{Title: "Baz"})
+		assert.Equal(t, storage.ErrArticleNotFound, err)
+	})
+}
+
+func TestArticleStorage_replica(t *testing.T) {
+	t.Parallel()
+
+	// Replica is a separate database, so it behaves as a replica which lags behind.
+	primary, teardown := setupTestDB(t)
+	defer teardown()
+	replica, teardownReplica := setupTestDBWithName(t, t.Name()+"_replica")
+	defer teardownReplica()
+	require.NoError(t, migrateArticle(primary))
+	require.NoError(t, migrateArticle(replica))
+
+	db, err := postgres.New(connString(primary), postgres.Options{ReplicaURLs: []string{connString(replica)}}, primary.Logger)
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, db.Connect(ctx))
+	defer db.Close()
+
+	store := NewArticleStorage(db)
+	article := storage.Article{Title: "Foo", Slug: "foo"}
+
+	require.NoError(t, store.StoreArticles(ctx, []storage.Article{article}))
+	articles, err := store.FilterArticles(ctx, storage.ArticleFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, articles, "read from replica")
+
+	ctx = postgres.TrackWrites(ctx)
+	article.Slug = "bar"
+	require.NoError(t, store.StoreArticles(ctx, []storage.Article{article}))
+	articles, err = store.FilterArticles(ctx, storage.ArticleFilter{})
+	require.NoError(t, err)
+	assert.Len(t, articles, 2, "read from primary after write")
+}
+
+func TestArticleStorage_deadline(t *testing.T) {
+	t.Parallel()
+
+	testDB, teardown := setupTestDB(t)
+	defer teardown()
+
+	// Single connection, so the timeout is checked on the connection which was acquired with deadline.
+	db, err := postgres.New(connString(testDB), postgres.Options{StatementTimeout: time.Minute, MaxConns: 1}, testDB.Logger)
+	require.NoError(t, err)
+	require.NoError(t, db.Connect(context.Background()))
+	defer db.Close()
+
+	// Deadline is far enough, so it doesn't cancel the query by itself.
+	const deadline = 10 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	defer cancel()
+	conn, err := db.Session.Acquire(ctx)
+	require.NoError(t, err)
+	var setting string
+	err = conn.QueryRow(ctx, "SHOW statement_timeout").Scan(&setting)
+	conn.Release()
+	require.NoError(t, err)
+	assert.NotEqual(t, "1min", setting, "statement_timeout is set from deadline")
+	timeout, err := time.ParseDuration(setting)
+	require.NoError(t, err, setting)
+	assert.True(t, timeout > 0 && timeout <= deadline, "statement_timeout %s is within deadline", setting)
+
+	require.NoError(t, db.Read(context.Background()).QueryRow(context.Background(), "SHOW statement_timeout").Scan(&setting))
+	assert.Equal(t, "1min", setting, "restored on release")
+
+	longCtx, cancelLong := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancelLong()
+	require.NoError(t, db.Read(longCtx).QueryRow(longCtx, "SHOW statement_timeout").Scan(&setting))
+	assert.Equal(t, "1min", setting, "deadline doesn't loosen session timeout")
+
+	tx := postgres.NewTxManager(db, postgres.TxOptions{})
+	err = tx.InTx(ctx, func(ctx context.Context) error {
+		return db.Write(ctx).QueryRow(ctx, "SHOW statement_timeout").Scan(&setting)
+	})
+	require.NoError(t, err)
+	timeout, err = time.ParseDuration(setting)
+	require.NoError(t, err, setting)
+	assert.True(t, timeout > 0 && timeout <= deadline, "statement_timeout %s is set in transaction", setting)
+	require.NoError(t, db.Read(context.Background()).QueryRow(context.Background(), "SHOW statement_timeout").Scan(&setting))
+	assert.Equal(t, "1min", setting, "restored on commit")
+}
+
+func TestArticleStorage_notify(t *testing.T) {
+	t.Parallel()
+
+	db, teardown := setupTestDB(t)
+	defer teardown()
+	require.NoError(t, migrateArticle(db))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	payloads := make(chan string, 10)
+	go db.Listen(ctx, ArticleChangedChannel, func(payload string) { payloads <- payload })
+
+	assert.Equal(t, "", <-payloads, "called on connect")
+	store := NewArticleStorage(db)
+	require.NoError(t, store.StoreArticles(ctx, []storage.Article{{Title: "Foo", Slug: "foo"}}))
+	select {
+	case payload := <-payloads:
+		assert.Equal(t, "article", payload)
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification is not received")
+	}
+}
+
+func TestArticleStorage_conformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestArticleRepository(t, func(t *testing.T) storage.ArticleRepository {
+		// Short name, since subtest names are longer than the limit of database name.
+		db, teardown := setupTestDBWithName(t, "conformance")
+		t.Cleanup(teardown)
+		require.NoError(t, migrateArticle(db))
+		return NewArticleStorage(db)
+	})
 }
 
 func countArticles(db *postgres.DB) (int, error) {
@@ -149,11 +450,38 @@ func countArticles(db *postgres.DB) (int, error) {
 func migrateArticle(db *postgres.DB) error {
 	// language=PostgreSQL
 	const schema = `
+		CREATE TABLE author (
+			id          SERIAL      PRIMARY KEY,
+			name        text        NOT NULL
+		);
+
 		CREATE TABLE article (
 			id          SERIAL      PRIMARY KEY,
 			title       text        NOT NULL,
-			slug        text        UNIQUE NOT NULL
+			slug        text        UNIQUE NOT NULL,
+			author_id   integer     REFERENCES author (id) ON DELETE SET NULL
+		);
+
+		CREATE TABLE article_tag (
+			article_id  integer     NOT NULL REFERENCES article (id) ON DELETE CASCADE,
+			tag         text        NOT NULL,
+			PRIMARY KEY (article_id, tag)
+		);
+
+		CREATE TABLE slug_history (
+			slug        text        PRIMARY KEY,
+			article_id  integer     NOT NULL REFERENCES article (id) ON DELETE CASCADE
 		);
+
+		CREATE FUNCTION notify_article_changed() RETURNS trigger AS $$
+		BEGIN
+			PERFORM pg_notify('article_changed', TG_TABLE_NAME);
+			RETURN NULL;
+		END;
+		$$ LANGUAGE plpgsql;
+
+		CREATE TRIGGER article_notify AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON article
+			FOR EACH STATEMENT EXECUTE PROCEDURE notify_article_changed();
 	`
 	_, err := db.Session.Exec(context.Background(), schema)
 	return err
@@ -0,0 +1,15 @@
+package rdb
+
+import (
+	"github.com/Masterminds/squirrel"
+
+	"github.com/agalitsyn/go-app/internal/pkg/filter"
+	"github.com/agalitsyn/go-app/internal/storage/sqlfilter"
+)
+
+// compileArticleFilter converts filter expression into squirrel conditions, contains is matched with ILIKE.
+func compileArticleFilter(e filter.Expr) (squirrel.Sqlizer, error) {
+	return sqlfilter.CompileArticleFilter(e, func(column, pattern string) squirrel.Sqlizer {
+		return squirrel.ILike{column: pattern}
+	})
+}
@@ -0,0 +1,14 @@
+package rdb
+
+import (
+	"errors"
+
+	"github.com/jackc/pgconn"
+)
+
+const uniqueViolationCode = "23505"
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode
+}
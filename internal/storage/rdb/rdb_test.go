@@ -65,6 +65,12 @@ func setupTestDBWithName(t *testing.T, name string) (*postgres.DB, func()) {
 	return &postgres.DB{Session: conn, Logger: logger}, teardown
 }
 
+// connString of test database, which can be passed to postgres.New.
+func connString(db *postgres.DB) string {
+	cfg := db.Session.Config().ConnConfig
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Database)
+}
+
 func dbCreate(ctx context.Context, cfg *pgx.ConnConfig, name string) error {
 	cfg.Database = managementDB
 	conn, err := pgx.ConnectConfig(ctx, cfg)
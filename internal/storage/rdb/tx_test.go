@@ -0,0 +1,127 @@
+package rdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agalitsyn/go-app/internal/pkg/postgres"
+	"github.com/agalitsyn/go-app/internal/storage"
+)
+
+const (
+	serializationFailureCode = "40001"
+	deadlockDetectedCode     = "40P01"
+)
+
+// raise fails current statement with server error of the code, which aborts the transaction.
+func raise(ctx context.Context, db *postgres.DB, code string) error {
+	_, err := db.Write(ctx).Exec(ctx, fmt.Sprintf("DO $$ BEGIN RAISE EXCEPTION 'test' USING ERRCODE = '%s'; END $$", code))
+	return err
+}
+
+func TestTxManager_InTx(t *testing.T) {
+	t.Parallel()
+
+	db, teardown := setupTestDB(t)
+	defer teardown()
+	require.NoError(t, migrateArticle(db))
+
+	store := NewArticleStorage(db)
+	tx := postgres.NewTxManager(db, postgres.TxOptions{MaxAttempts: 3})
+	ctx := context.Background()
+
+	t.Run("retry", func(t *testing.T) {
+		tests := []struct {
+			name string
+			code string
+		}{
+			{name: "serialization failure", code: serializationFailureCode},
+			{name: "deadlock", code: deadlockDetectedCode},
+		}
+		for _, tt := range tests {
+			slug := "retry-" + tt.code
+			attempts := 0
+			err := tx.InTx(ctx, func(ctx context.Context) error {
+				attempts++
+				title := fmt.Sprintf("Attempt %d", attempts)
+				if err := store.StoreArticles(ctx, []storage.Article{{Title: title, Slug: slug}}); err != nil {
+					return err
+				}
+				if attempts == 1 {
+					return raise(ctx, db, tt.code)
+				}
+				return nil
+			})
+			require.NoError(t, err, tt.name)
+			assert.Equal(t, 2, attempts, tt.name)
+
+			article, err := store.GetArticle(ctx, slug)
+			require.NoError(t, err, tt.name)
+			assert.Equal(t, "Attempt 2", article.Title, "%s: first attempt is rolled back", tt.name)
+		}
+	})
+
+	t.Run("max attempts", func(t *testing.T) {
+		attempts := 0
+		err := tx.InTx(ctx, func(ctx context.Context) error {
+			attempts++
+			return raise(ctx, db, serializationFailureCode)
+		})
+		var pgErr *pgconn.PgError
+		require.True(t, errors.As(err, &pgErr), "last error is returned: %v", err)
+		assert.Equal(t, serializationFailureCode, pgErr.Code)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("no retry", func(t *testing.T) {
+		errFailed := errors.New("failed")
+		tests := []struct {
+			name string
+			fn   func(ctx context.Context) error
+		}{
+			{name: "error", fn: func(ctx context.Context) error { return errFailed }},
+			{name: "unique violation", fn: func(ctx context.Context) error { return raise(ctx, db, "23505") }},
+		}
+		for _, tt := range tests {
+			attempts := 0
+			err := tx.InTx(ctx, func(ctx context.Context) error {
+				attempts++
+				return tt.fn(ctx)
+			})
+			assert.Error(t, err, tt.name)
+			assert.Equal(t, 1, attempts, tt.name)
+		}
+	})
+
+	t.Run("join outer", func(t *testing.T) {
+		var outerAttempts, innerAttempts int
+		err := tx.InTx(ctx, func(ctx context.Context) error {
+			outerAttempts++
+			var outerID int64
+			if err := db.Write(ctx).QueryRow(ctx, "SELECT txid_current()").Scan(&outerID); err != nil {
+				return err
+			}
+			return tx.InTx(ctx, func(ctx context.Context) error {
+				innerAttempts++
+				var innerID int64
+				if err := db.Write(ctx).QueryRow(ctx, "SELECT txid_current()").Scan(&innerID); err != nil {
+					return err
+				}
+				assert.Equal(t, outerID, innerID, "nested call joins outer transaction")
+				if innerAttempts == 1 {
+					return raise(ctx, db, serializationFailureCode)
+				}
+				return nil
+			})
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, outerAttempts, "outer transaction is retried as a whole")
+		assert.Equal(t, 2, innerAttempts, "nested call doesn't retry on its own")
+	})
+}
@@ -0,0 +1,65 @@
+package rdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/agalitsyn/go-app/internal/pkg/postgres"
+	"github.com/agalitsyn/go-app/internal/storage"
+)
+
+type UserStorage struct {
+	db *postgres.DB
+}
+
+func NewUserStorage(db *postgres.DB) *UserStorage {
+	return &UserStorage{db: db}
+}
+
+func (s *UserStorage) GetUserByEmail(ctx context.Context, email string) (storage.User, error) {
+	// language=PostgreSQL
+	const query = `SELECT id, email, password_hash FROM app_user WHERE email = $1;`
+
+	// User may be just created, e.g. by `api user create` followed by `api apikey create`, so primary is queried.
+	var user storage.User
+	err := s.db.Primary(ctx).QueryRow(ctx, query, strings.ToLower(email)).Scan(
+		&user.ID,
+		&user.Email,
+		&user.PasswordHash,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.User{}, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return storage.User{}, fmt.Errorf("could not perform query: %w", err)
+	}
+	return user, nil
+}
+
+func (s *UserStorage) CreateUser(ctx context.Context, user storage.User) (storage.User, error) {
+	user.Email = strings.ToLower(user.Email)
+
+	// language=PostgreSQL
+	const query = `INSERT INTO app_user ( email, password_hash ) VALUES ( $1, $2 ) RETURNING id;`
+	err := s.db.Write(ctx).QueryRow(ctx, query, user.Email, user.PasswordHash).Scan(&user.ID)
+	if isUniqueViolation(err) {
+		return storage.User{}, storage.ErrUserExists
+	}
+	if err != nil {
+		return storage.User{}, fmt.Errorf("could not perform query: %w", err)
+	}
+	return user, nil
+}
+
+func (s *UserStorage) CreateAPIKey(ctx context.Context, key storage.APIKey) (storage.APIKey, error) {
+	// language=PostgreSQL
+	const query = `INSERT INTO api_key ( user_id, name, key_hash ) VALUES ( $1, $2, $3 ) RETURNING id;`
+	if err := s.db.Write(ctx).QueryRow(ctx, query, key.UserID, key.Name, key.KeyHash).Scan(&key.ID); err != nil {
+		return storage.APIKey{}, fmt.Errorf("could not perform query: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,65 @@
+package rdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agalitsyn/go-app/internal/pkg/postgres"
+	"github.com/agalitsyn/go-app/internal/storage"
+)
+
+func TestUserStorage(t *testing.T) {
+	t.Parallel()
+
+	db, teardown := setupTestDB(t)
+	defer teardown()
+
+	err := migrateUser(db)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	store := NewUserStorage(db)
+
+	user, err := store.CreateUser(ctx, storage.User{Email: "Admin@Example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+	assert.NotZero(t, user.ID)
+
+	_, err = store.CreateUser(ctx, storage.User{Email: "admin@example.com", PasswordHash: "hash"})
+	assert.ErrorIs(t, err, storage.ErrUserExists)
+
+	found, err := store.GetUserByEmail(ctx, "ADMIN@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, user, found)
+
+	_, err = store.GetUserByEmail(ctx, "nobody@example.com")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	key, err := store.CreateAPIKey(ctx, storage.APIKey{UserID: user.ID, Name: "ci", KeyHash: "key-hash"})
+	require.NoError(t, err)
+	assert.NotZero(t, key.ID)
+}
+
+func migrateUser(db *postgres.DB) error {
+	// language=PostgreSQL
+	const schema = `
+		CREATE TABLE app_user (
+			id              SERIAL      PRIMARY KEY,
+			email           text        UNIQUE NOT NULL,
+			password_hash   text        NOT NULL,
+			created_at      timestamptz NOT NULL DEFAULT now()
+		);
+
+		CREATE TABLE api_key (
+			id          SERIAL      PRIMARY KEY,
+			user_id     integer     NOT NULL REFERENCES app_user (id) ON DELETE CASCADE,
+			name        text        NOT NULL,
+			key_hash    text        UNIQUE NOT NULL,
+			created_at  timestamptz NOT NULL DEFAULT now()
+		);
+	`
+	_, err := db.Session.Exec(context.Background(), schema)
+	return err
+}
@@ -0,0 +1,105 @@
+// Package sqlfilter compiles filter expressions into squirrel conditions shared by SQL storages,
+// which differ only in case-insensitive matching.
+package sqlfilter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/agalitsyn/go-app/internal/pkg/filter"
+	"github.com/agalitsyn/go-app/internal/storage"
+)
+
+// LikeFunc builds case-insensitive match of column with LIKE pattern escaped with backslash.
+type LikeFunc func(column, pattern string) squirrel.Sqlizer
+
+// CompileArticleFilter converts filter expression into conditions on article table.
+// Expression must be validated against storage.ArticleFilterSchema before.
+func CompileArticleFilter(e filter.Expr, like LikeFunc) (squirrel.Sqlizer, error) {
+	switch e := e.(type) {
+	case *filter.Logical:
+		left, err := CompileArticleFilter(e.Left, like)
+		if err != nil {
+			return nil, err
+		}
+		right, err := CompileArticleFilter(e.Right, like)
+		if err != nil {
+			return nil, err
+		}
+		if e.Op == filter.Or {
+			return squirrel.Or{left, right}, nil
+		}
+		return squirrel.And{left, right}, nil
+	case *filter.Not:
+		x, err := CompileArticleFilter(e.X, like)
+		if err != nil {
+			return nil, err
+		}
+		return squirrel.Expr("NOT (?)", x), nil
+	case *filter.Comparison:
+		return compileArticleComparison(e, like)
+	default:
+		return nil, fmt.Errorf("unknown filter expression %T", e)
+	}
+}
+
+func compileArticleComparison(c *filter.Comparison, like LikeFunc) (squirrel.Sqlizer, error) {
+	switch c.Field {
+	case storage.ArticleFieldID, storage.ArticleFieldTitle, storage.ArticleFieldSlug:
+		return compileComparison("article."+c.Field, c, like)
+	case storage.ArticleFilterFieldTag:
+		// Negative condition means that article has no such tag at all.
+		op, exists := c.Op, "EXISTS"
+		if c.Op == filter.OpNotEq {
+			op, exists = filter.OpEq, "NOT EXISTS"
+		}
+		cond, err := compileComparison("article_tag.tag", &filter.Comparison{Field: c.Field, Op: op, Values: c.Values}, like)
+		if err != nil {
+			return nil, err
+		}
+		return squirrel.Expr(exists+" (SELECT 1 FROM article_tag WHERE article_tag.article_id = article.id AND (?))", cond), nil
+	default:
+		return nil, fmt.Errorf("unknown filter field: %s", c.Field)
+	}
+}
+
+func compileComparison(column string, c *filter.Comparison, like LikeFunc) (squirrel.Sqlizer, error) {
+	if len(c.Values) == 0 {
+		return nil, fmt.Errorf("no values for filter field: %s", c.Field)
+	}
+	v := c.Values[0]
+
+	switch c.Op {
+	case filter.OpEq:
+		return squirrel.Eq{column: v}, nil
+	case filter.OpNotEq:
+		return squirrel.NotEq{column: v}, nil
+	case filter.OpGt:
+		return squirrel.Gt{column: v}, nil
+	case filter.OpGtOrEq:
+		return squirrel.GtOrEq{column: v}, nil
+	case filter.OpLt:
+		return squirrel.Lt{column: v}, nil
+	case filter.OpLtOrEq:
+		return squirrel.LtOrEq{column: v}, nil
+	case filter.OpContains:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("filter field %s: contains expects string", c.Field)
+		}
+		return like(column, "%"+EscapeLike(s)+"%"), nil
+	case filter.OpIn:
+		return squirrel.Eq{column: c.Values}, nil
+	default:
+		return nil, fmt.Errorf("unknown filter operator: %s", c.Op)
+	}
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike escapes wildcards of LIKE pattern with backslash.
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
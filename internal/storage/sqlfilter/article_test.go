@@ -0,0 +1,69 @@
+package sqlfilter
+
+import (
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agalitsyn/go-app/internal/pkg/filter"
+	"github.com/agalitsyn/go-app/internal/storage"
+)
+
+func TestCompileArticleFilter(t *testing.T) {
+	t.Parallel()
+
+	like := func(column, pattern string) squirrel.Sqlizer {
+		return squirrel.Like{column: pattern}
+	}
+
+	tests := []struct {
+		input string
+		sql   string
+		args  []interface{}
+	}{
+		{
+			input: `title~"50%" and id>100`,
+			sql:   `(article.title LIKE ? AND article.id > ?)`,
+			args:  []interface{}{`%50\%%`, int64(100)},
+		},
+		{
+			input: `not slug = "foo" or tag in ("a","b")`,
+			sql:   `(NOT (article.slug = ?) OR EXISTS (SELECT 1 FROM article_tag WHERE article_tag.article_id = article.id AND (article_tag.tag IN (?,?))))`,
+			args:  []interface{}{"foo", "a", "b"},
+		},
+		{
+			input: `tag != "a"`,
+			sql:   `NOT EXISTS (SELECT 1 FROM article_tag WHERE article_tag.article_id = article.id AND (article_tag.tag = ?))`,
+			args:  []interface{}{"a"},
+		},
+		{
+			input: `id <= 5 or tag ~ "g_"`,
+			sql:   `(article.id <= ? OR EXISTS (SELECT 1 FROM article_tag WHERE article_tag.article_id = article.id AND (article_tag.tag LIKE ?)))`,
+			args:  []interface{}{int64(5), `%g\_%`},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			e, err := filter.ParseWithSchema(tt.input, storage.ArticleFilterSchema)
+			require.NoError(t, err)
+
+			cond, err := CompileArticleFilter(e, like)
+			require.NoError(t, err)
+
+			sql, args, err := squirrel.Select("id").From("article").Where(cond).ToSql()
+			require.NoError(t, err)
+
+			assert.Equal(t, "SELECT id FROM article WHERE "+tt.sql, sql)
+			assert.Equal(t, tt.args, args)
+		})
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, `50\% of a\_b \\ c`, EscapeLike(`50% of a_b \ c`))
+}
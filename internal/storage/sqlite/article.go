@@ -0,0 +1,460 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/agalitsyn/go-app/internal/pkg/slug"
+	"github.com/agalitsyn/go-app/internal/storage"
+	"github.com/agalitsyn/go-app/internal/storage/sqlfilter"
+)
+
+// ArticleStorage uses single connection of DB, so rows must be closed before the next query.
+type ArticleStorage struct {
+	db *DB
+}
+
+func NewArticleStorage(db *DB) *ArticleStorage {
+	return &ArticleStorage{db: db}
+}
+
+func (s *ArticleStorage) FilterArticles(ctx context.Context, params storage.ArticleFilter) ([]storage.Article, error) {
+	fields, err := articleSelectFields(params)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, 0, len(fields))
+	for _, f := range fields {
+		columns = append(columns, articleColumns[f])
+	}
+	where, err := articleConditions(params)
+	if err != nil {
+		return nil, err
+	}
+
+	qb := squirrel.Select(columns...).
+		From("article").
+		Where(where).
+		OrderBy("id ASC")
+
+	if params.Limit > 0 {
+		qb = qb.Limit(params.Limit)
+		if params.Offset > 0 {
+			qb = qb.Offset(params.Offset)
+		}
+	} else if params.Offset > 0 {
+		// SQLite doesn't accept OFFSET without LIMIT.
+		qb = qb.Suffix("LIMIT -1 OFFSET ?", params.Offset)
+	}
+
+	query, args := qb.MustSql()
+	rows, err := s.db.Session.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not perform query: %w", err)
+	}
+	defer rows.Close()
+
+	res := make([]storage.Article, 0, params.Limit)
+	for rows.Next() {
+		var article storage.Article
+		dest := make([]interface{}, 0, len(fields))
+		for _, f := range fields {
+			dest = append(dest, articleScanDest(&article, f))
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("could not scan row: %w", err)
+		}
+		res = append(res, article)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not iterate rows: %w", err)
+	}
+	rows.Close()
+
+	if params.WithAuthor {
+		if err = s.loadAuthors(ctx, res); err != nil {
+			return nil, err
+		}
+	}
+	if params.WithTags {
+		if err = s.loadTags(ctx, res); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// articleConditions builds WHERE clause from filter params, nil means no conditions.
+func articleConditions(params storage.ArticleFilter) (squirrel.Sqlizer, error) {
+	if params.Expr == nil {
+		return nil, nil
+	}
+	where, err := compileArticleFilter(params.Expr)
+	if err != nil {
+		return nil, fmt.Errorf("could not compile filter: %w", err)
+	}
+	return where, nil
+}
+
+var articleColumns = map[string]string{
+	storage.ArticleFieldID:       "id",
+	storage.ArticleFieldTitle:    "title",
+	storage.ArticleFieldSlug:     "slug",
+	storage.ArticleFieldAuthorID: "COALESCE(author_id, 0)",
+}
+
+// articleSelectFields returns fields to select in stable order.
+// ID is always selected and author_id is required for loading authors.
+func articleSelectFields(params storage.ArticleFilter) ([]string, error) {
+	if len(params.Fields) == 0 {
+		return storage.ArticleFields, nil
+	}
+
+	requested := map[string]bool{
+		storage.ArticleFieldID:       true,
+		storage.ArticleFieldAuthorID: params.WithAuthor,
+	}
+	for _, f := range params.Fields {
+		if _, ok := articleColumns[f]; !ok {
+			return nil, fmt.Errorf("unknown article field: %s", f)
+		}
+		requested[f] = true
+	}
+
+	var res []string
+	for _, f := range storage.ArticleFields {
+		if requested[f] {
+			res = append(res, f)
+		}
+	}
+	return res, nil
+}
+
+func articleScanDest(article *storage.Article, field string) interface{} {
+	switch field {
+	case storage.ArticleFieldTitle:
+		return &article.Title
+	case storage.ArticleFieldSlug:
+		return &article.Slug
+	case storage.ArticleFieldAuthorID:
+		return &article.AuthorID
+	default:
+		return &article.ID
+	}
+}
+
+// loadAuthors fetches authors of all articles with one query.
+func (s *ArticleStorage) loadAuthors(ctx context.Context, articles []storage.Article) error {
+	var ids []int
+	for i := range articles {
+		if articles[i].AuthorID != 0 {
+			ids = append(ids, articles[i].AuthorID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query, args := squirrel.Select("id", "name").From("author").Where(squirrel.Eq{"id": ids}).MustSql()
+	rows, err := s.db.Session.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("could not perform query: %w", err)
+	}
+	defer rows.Close()
+
+	authors := make(map[int]*storage.Author, len(ids))
+	for rows.Next() {
+		var author storage.Author
+		if err = rows.Scan(&author.ID, &author.Name); err != nil {
+			return fmt.Errorf("could not scan row: %w", err)
+		}
+		authors[author.ID] = &author
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("could not iterate rows: %w", err)
+	}
+
+	for i := range articles {
+		articles[i].Author = authors[articles[i].AuthorID]
+	}
+	return nil
+}
+
+// loadTags fetches tags of all articles with one query.
+func (s *ArticleStorage) loadTags(ctx context.Context, articles []storage.Article) error {
+	if len(articles) == 0 {
+		return nil
+	}
+
+	ids := make([]int, 0, len(articles))
+	for i := range articles {
+		ids = append(ids, articles[i].ID)
+	}
+
+	query, args := squirrel.Select("article_id", "tag").
+		From("article_tag").
+		Where(squirrel.Eq{"article_id": ids}).
+		OrderBy("tag").
+		MustSql()
+	rows, err := s.db.Session.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("could not perform query: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[int][]string, len(ids))
+	for rows.Next() {
+		var (
+			id  int
+			tag string
+		)
+		if err = rows.Scan(&id, &tag); err != nil {
+			return fmt.Errorf("could not scan row: %w", err)
+		}
+		tags[id] = append(tags[id], tag)
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("could not iterate rows: %w", err)
+	}
+
+	for i := range articles {
+		articles[i].Tags = tags[articles[i].ID]
+	}
+	return nil
+}
+
+// CountArticles is always exact, SQLite has no row estimates.
+func (s *ArticleStorage) CountArticles(ctx context.Context, params storage.ArticleFilter, mode storage.CountMode) (int64, error) {
+	where, err := articleConditions(params)
+	if err != nil {
+		return 0, err
+	}
+
+	query, args := squirrel.Select("COUNT(*)").From("article").Where(where).MustSql()
+	var count int64
+	if err := s.db.Session.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("could not perform query: %w", err)
+	}
+	return count, nil
+}
+
+func (s *ArticleStorage) GetArticle(ctx context.Context, slug string) (storage.Article, error) {
+	const query = `
+		SELECT id, title, slug, COALESCE(author_id, 0), 0 AS priority
+		FROM article
+		WHERE slug = ?1
+		UNION ALL
+		SELECT a.id, a.title, a.slug, COALESCE(a.author_id, 0), 1 AS priority
+		FROM slug_history h
+		JOIN article a ON a.id = h.article_id
+		WHERE h.slug = ?1
+		ORDER BY priority
+		LIMIT 1;
+	`
+	var (
+		article  storage.Article
+		priority int
+	)
+	err := s.db.Session.QueryRowContext(ctx, query, strings.ToLower(slug)).Scan(
+		&article.ID,
+		&article.Title,
+		&article.Slug,
+		&article.AuthorID,
+		&priority,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Article{}, storage.ErrArticleNotFound
+	}
+	if err != nil {
+		return storage.Article{}, fmt.Errorf("could not perform query: %w", err)
+	}
+	return article, nil
+}
+
+func (s *ArticleStorage) UpdateArticle(ctx context.Context, slug string, article storage.Article) error {
+	oldSlug := strings.ToLower(slug)
+	newSlug := strings.ToLower(article.Slug)
+	if newSlug == "" {
+		newSlug = oldSlug
+	}
+
+	return s.db.InTx(ctx, func(tx *sql.Tx) error {
+		const updateQuery = `UPDATE article SET title = ?, slug = ? WHERE slug = ? RETURNING id;`
+		var id int
+		err := tx.QueryRowContext(ctx, updateQuery, article.Title, newSlug, oldSlug).Scan(&id)
+		if isUniqueViolation(err) {
+			return storage.ErrArticleConflict
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrArticleNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("could not perform query: %w", err)
+		}
+		if newSlug == oldSlug {
+			return nil
+		}
+
+		// Old slug goes to history and new slug is removed from there,
+		// so GetArticle always resolves current slug first.
+		const historyQuery = `
+			INSERT INTO slug_history (slug, article_id) VALUES (?, ?)
+			ON CONFLICT (slug) DO UPDATE SET article_id = excluded.article_id;
+		`
+		if _, err = tx.ExecContext(ctx, historyQuery, oldSlug, id); err != nil {
+			return fmt.Errorf("could not store slug history: %w", err)
+		}
+		const cleanupQuery = `DELETE FROM slug_history WHERE slug = ?;`
+		if _, err = tx.ExecContext(ctx, cleanupQuery, newSlug); err != nil {
+			return fmt.Errorf("could not clean slug history: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *ArticleStorage) UniqueArticleSlug(ctx context.Context, base string) (string, error) {
+	base = strings.ToLower(base)
+
+	// Slugs from history are taken as well, otherwise old links will point to the new article.
+	const query = `
+		SELECT slug FROM article WHERE slug = ?1 OR slug LIKE ?2 ESCAPE '\'
+		UNION
+		SELECT slug FROM slug_history WHERE slug = ?1 OR slug LIKE ?2 ESCAPE '\';
+	`
+	rows, err := s.db.Session.QueryContext(ctx, query, base, sqlfilter.EscapeLike(base)+"-%")
+	if err != nil {
+		return "", fmt.Errorf("could not perform query: %w", err)
+	}
+	defer rows.Close()
+
+	taken := make(map[string]bool)
+	for rows.Next() {
+		var existing string
+		if err = rows.Scan(&existing); err != nil {
+			return "", fmt.Errorf("could not scan row: %w", err)
+		}
+		taken[existing] = true
+	}
+	if err = rows.Err(); err != nil {
+		return "", fmt.Errorf("could not iterate rows: %w", err)
+	}
+
+	return slug.Unique(base, func(candidate string) bool { return taken[candidate] }), nil
+}
+
+func (s *ArticleStorage) CreateArticle(ctx context.Context, article storage.Article) error {
+	// Article and its tags are stored atomically.
+	return s.db.InTx(ctx, func(tx *sql.Tx) error {
+		const query = `INSERT INTO article (title, slug) VALUES (?, ?) RETURNING id;`
+		var id int
+		err := tx.QueryRowContext(ctx, query, article.Title, strings.ToLower(article.Slug)).Scan(&id)
+		if isUniqueViolation(err) {
+			return storage.ErrArticleConflict
+		}
+		if err != nil {
+			return fmt.Errorf("could not perform query: %w", err)
+		}
+		return replaceTags(ctx, tx, id, article.Tags)
+	})
+}
+
+func (s *ArticleStorage) StoreArticles(ctx context.Context, articles []storage.Article) error {
+	if len(articles) == 0 {
+		return nil
+	}
+
+	// dedup for preventing ON CONFLICT loop
+	// sort for storing articles in the same order as rdb does
+	unique := make(map[string]storage.Article, len(articles))
+	ordered := make([]string, 0, len(articles))
+	for _, obj := range articles {
+		if obj.Slug == "" {
+			continue
+		}
+
+		slug := strings.ToLower(obj.Slug)
+		_, visited := unique[slug]
+		if visited {
+			continue
+		}
+		unique[slug] = obj
+		ordered = append(ordered, slug)
+	}
+	sort.Strings(ordered)
+
+	// Articles and their tags are stored atomically.
+	return s.db.InTx(ctx, func(tx *sql.Tx) error {
+		const query = `
+			INSERT INTO article (title, slug) VALUES (?, ?)
+			ON CONFLICT (slug) DO UPDATE SET title = excluded.title
+			RETURNING id;
+		`
+		for _, slug := range ordered {
+			obj := unique[slug]
+			var id int
+			if err := tx.QueryRowContext(ctx, query, obj.Title, slug).Scan(&id); err != nil {
+				return fmt.Errorf("could not perform query: %w", err)
+			}
+			if err := replaceTags(ctx, tx, id, obj.Tags); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// replaceTags sets tags of article, nil tags are kept as is.
+func replaceTags(ctx context.Context, tx *sql.Tx, id int, tags []string) error {
+	if tags == nil {
+		return nil
+	}
+
+	deleteQuery, args, err := squirrel.Delete("article_tag").
+		Where(squirrel.Eq{"article_id": id}).
+		Where(squirrel.NotEq{"tag": tags}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("could not build query: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, deleteQuery, args...); err != nil {
+		return fmt.Errorf("could not delete tags: %w", err)
+	}
+
+	const insertQuery = `INSERT INTO article_tag (article_id, tag) VALUES (?, ?) ON CONFLICT DO NOTHING;`
+	for _, tag := range tags {
+		if _, err = tx.ExecContext(ctx, insertQuery, id, tag); err != nil {
+			return fmt.Errorf("could not store tags: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *ArticleStorage) DeleteArticles(ctx context.Context, articles []storage.Article) error {
+	slugs := make([]string, 0, len(articles))
+	for i := range articles {
+		if articles[i].Slug != "" {
+			slugs = append(slugs, strings.ToLower(articles[i].Slug))
+		}
+	}
+	if len(slugs) == 0 {
+		return nil
+	}
+
+	// Tags and slug history are deleted by foreign keys.
+	query, args, err := squirrel.Delete("article").Where(squirrel.Eq{"slug": slugs}).ToSql()
+	if err != nil {
+		return fmt.Errorf("could not build query: %w", err)
+	}
+	if _, err = s.db.Session.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("could not perform query: %w", err)
+	}
+	return nil
+}
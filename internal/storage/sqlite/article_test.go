@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agalitsyn/go-app/internal/storage"
+	"github.com/agalitsyn/go-app/internal/storage/storagetest"
+)
+
+func setupTestDB(t *testing.T) *DB {
+	db := New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, db.Open(context.Background()))
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestArticleStorage_conformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestArticleRepository(t, func(t *testing.T) storage.ArticleRepository {
+		return NewArticleStorage(setupTestDB(t))
+	})
+}
+
+func TestDB_InTx(t *testing.T) {
+	t.Parallel()
+
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	err := db.InTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO article (title, slug) VALUES ('Foo', 'foo');`); err != nil {
+			return err
+		}
+		return errors.New("failed")
+	})
+	assert.EqualError(t, err, "failed")
+
+	count, err := NewArticleStorage(db).CountArticles(ctx, storage.ArticleFilter{}, storage.CountExact)
+	require.NoError(t, err)
+	assert.Zero(t, count, "rolled back")
+}
+
+func TestDB_Open(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	ctx := context.Background()
+
+	db := New(path)
+	require.NoError(t, db.Open(ctx))
+	require.NoError(t, NewArticleStorage(db).StoreArticles(ctx, []storage.Article{{Title: "Foo", Slug: "foo"}}))
+	require.NoError(t, db.Close())
+
+	// Migrations are applied once, data is kept between restarts.
+	db = New(path)
+	require.NoError(t, db.Open(ctx))
+	defer db.Close()
+	article, err := NewArticleStorage(db).GetArticle(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "Foo", article.Title)
+
+	_, err = db.Session.Exec("PRAGMA user_version = 100;")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	assert.Error(t, New(path).Open(ctx), "newer schema")
+}
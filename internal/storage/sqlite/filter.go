@@ -0,0 +1,16 @@
+package sqlite
+
+import (
+	"github.com/Masterminds/squirrel"
+
+	"github.com/agalitsyn/go-app/internal/pkg/filter"
+	"github.com/agalitsyn/go-app/internal/storage/sqlfilter"
+)
+
+// compileArticleFilter converts filter expression into squirrel conditions.
+// LIKE of SQLite is case-insensitive for ASCII letters only and has no default escape character.
+func compileArticleFilter(e filter.Expr) (squirrel.Sqlizer, error) {
+	return sqlfilter.CompileArticleFilter(e, func(column, pattern string) squirrel.Sqlizer {
+		return squirrel.Expr(column+` LIKE ? ESCAPE '\'`, pattern)
+	})
+}
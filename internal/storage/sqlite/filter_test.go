@@ -0,0 +1,45 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agalitsyn/go-app/internal/pkg/filter"
+	"github.com/agalitsyn/go-app/internal/storage"
+)
+
+func TestCompileArticleFilter_contains(t *testing.T) {
+	t.Parallel()
+
+	// Other conditions are covered by tests of sqlfilter.
+	tests := []struct {
+		input string
+		sql   string
+		args  []interface{}
+	}{
+		{
+			input: `title~"50%" and tag~"go"`,
+			sql:   `(article.title LIKE ? ESCAPE '\' AND EXISTS (SELECT 1 FROM article_tag WHERE article_tag.article_id = article.id AND (article_tag.tag LIKE ? ESCAPE '\')))`,
+			args:  []interface{}{`%50\%%`, "%go%"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			e, err := filter.ParseWithSchema(tt.input, storage.ArticleFilterSchema)
+			require.NoError(t, err)
+
+			cond, err := compileArticleFilter(e)
+			require.NoError(t, err)
+
+			sql, args, err := squirrel.Select("id").From("article").Where(cond).ToSql()
+			require.NoError(t, err)
+
+			assert.Equal(t, "SELECT id FROM article WHERE "+tt.sql, sql)
+			assert.Equal(t, tt.args, args)
+		})
+	}
+}
@@ -0,0 +1,22 @@
+CREATE TABLE author (
+    id          INTEGER     PRIMARY KEY AUTOINCREMENT,
+    name        text        NOT NULL
+);
+
+CREATE TABLE article (
+    id          INTEGER     PRIMARY KEY AUTOINCREMENT,
+    title       text        NOT NULL,
+    slug        text        UNIQUE NOT NULL,
+    author_id   integer     REFERENCES author (id) ON DELETE SET NULL
+);
+
+CREATE TABLE article_tag (
+    article_id  integer     NOT NULL REFERENCES article (id) ON DELETE CASCADE,
+    tag         text        NOT NULL,
+    PRIMARY KEY (article_id, tag)
+);
+
+CREATE TABLE slug_history (
+    slug        text        PRIMARY KEY,
+    article_id  integer     NOT NULL REFERENCES article (id) ON DELETE CASCADE
+);
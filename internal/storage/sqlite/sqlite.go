@@ -0,0 +1,131 @@
+// Package sqlite implements storages in embedded SQLite database file for single instance deployments.
+// Driver is pure Go, so binaries are still built without cgo. Behavior follows rdb storages.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+
+	driver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+type DB struct {
+	path string
+
+	Session *sql.DB
+}
+
+func New(path string) *DB {
+	return &DB{path: path}
+}
+
+// Open opens database file, creating it if needed, and applies pending migrations.
+func (d *DB) Open(ctx context.Context) error {
+	// Foreign keys are off by default in SQLite, they are required for cascade deletes.
+	dsn := "file:" + d.path + "?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return fmt.Errorf("could not open database: %w", err)
+	}
+	// SQLite allows only one writer at a time, single connection serializes transactions
+	// instead of failing them with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	if err = migrate(ctx, db); err != nil {
+		db.Close()
+		return err
+	}
+	d.Session = db
+	return nil
+}
+
+func (d *DB) Close() error {
+	if d.Session == nil {
+		return nil
+	}
+	return d.Session.Close()
+}
+
+// Ping returns error if database is not opened.
+func (d *DB) Ping(ctx context.Context) error {
+	if d.Session == nil {
+		return errors.New("not opened")
+	}
+	return d.Session.PingContext(ctx)
+}
+
+// InTx runs fn in a transaction, which is committed if fn returns nil.
+func (d *DB) InTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := d.Session.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	if err = fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
+
+// migrate applies migrations which are newer than user_version of database in one transaction.
+// Migrations are numbered from 1 and can't be rolled back.
+func migrate(ctx context.Context, db *sql.DB) error {
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("could not list migrations: %w", err)
+	}
+	sort.Strings(names)
+
+	var version int
+	if err = db.QueryRowContext(ctx, "PRAGMA user_version;").Scan(&version); err != nil {
+		return fmt.Errorf("could not get schema version: %w", err)
+	}
+	if version > len(names) {
+		return fmt.Errorf("database schema version %d is newer than latest known %d", version, len(names))
+	}
+	if version == len(names) {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	// Rollback after commit is no-op.
+	defer tx.Rollback()
+
+	for _, name := range names[version:] {
+		migration, err := migrations.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("could not read migration %s: %w", name, err)
+		}
+		if _, err = tx.ExecContext(ctx, string(migration)); err != nil {
+			return fmt.Errorf("could not apply migration %s: %w", name, err)
+		}
+	}
+	// PRAGMA doesn't accept parameters.
+	if _, err = tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d;", len(names))); err != nil {
+		return fmt.Errorf("could not set schema version: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit migrations: %w", err)
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *driver.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
@@ -0,0 +1,374 @@
+// Package storagetest implements conformance tests of storage interfaces, so all implementations behave the same.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agalitsyn/go-app/internal/pkg/filter"
+	"github.com/agalitsyn/go-app/internal/storage"
+)
+
+// TestArticleRepository runs conformance tests of storage.ArticleRepository in parallel subtests,
+// newRepo must return an empty repository for every subtest.
+func TestArticleRepository(t *testing.T, newRepo func(t *testing.T) storage.ArticleRepository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo storage.ArticleRepository)
+	}{
+		{name: "CreateArticle", test: testCreateArticle},
+		{name: "CreateArticle concurrency", test: testCreateArticleConcurrency},
+		{name: "StoreArticles upsert", test: testStoreArticlesUpsert},
+		{name: "StoreArticles dedup", test: testStoreArticlesDedup},
+		{name: "StoreArticles tags", test: testStoreArticlesTags},
+		{name: "DeleteArticles", test: testDeleteArticles},
+		{name: "FilterArticles limit", test: testFilterArticlesLimit},
+		{name: "FilterArticles fields", test: testFilterArticlesFields},
+		{name: "FilterArticles expression", test: testFilterArticlesExpr},
+		{name: "GetArticle", test: testGetArticle},
+		{name: "UpdateArticle", test: testUpdateArticle},
+		{name: "UniqueArticleSlug", test: testUniqueArticleSlug},
+		{name: "concurrency", test: testArticlesConcurrency},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tt.test(t, newRepo(t))
+		})
+	}
+}
+
+func testCreateArticle(t *testing.T, repo storage.ArticleRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.CreateArticle(ctx, storage.Article{Title: "Foo", Slug: "Foo", Tags: []string{"go", "books", "go"}}))
+
+	err := repo.CreateArticle(ctx, storage.Article{Title: "Other", Slug: "FOO"})
+	assert.ErrorIs(t, err, storage.ErrArticleConflict)
+
+	articles := filterArticles(t, repo, storage.ArticleFilter{WithTags: true})
+	require.Len(t, articles, 1)
+	assert.Equal(t, "Foo", articles[0].Title, "existing article is not overwritten")
+	assert.Equal(t, "foo", articles[0].Slug, "slug is lowercased")
+	assert.Equal(t, []string{"books", "go"}, articles[0].Tags)
+}
+
+func testCreateArticleConcurrency(t *testing.T, repo storage.ArticleRepository) {
+	ctx := context.Background()
+	const workers = 10
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created []string
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			title := fmt.Sprintf("Foo %d", i)
+			err := repo.CreateArticle(ctx, storage.Article{Title: title, Slug: "foo"})
+			if errors.Is(err, storage.ErrArticleConflict) {
+				return
+			}
+			if assert.NoError(t, err) {
+				mu.Lock()
+				created = append(created, title)
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.Len(t, created, 1, "only one of concurrent creates succeeds")
+	article, err := repo.GetArticle(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, created[0], article.Title)
+}
+
+func testStoreArticlesUpsert(t *testing.T, repo storage.ArticleRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.StoreArticles(ctx, []storage.Article{{Title: "Foo", Slug: "foo"}}))
+	require.NoError(t, repo.StoreArticles(ctx, []storage.Article{{Title: "New Foo", Slug: "FOO"}, {Title: "Bar", Slug: "bar"}}))
+
+	articles := filterArticles(t, repo, storage.ArticleFilter{})
+	require.Len(t, articles, 2)
+	assert.Equal(t, "foo", articles[0].Slug, "existing article keeps id")
+	assert.Equal(t, "New Foo", articles[0].Title)
+	assert.Equal(t, "bar", articles[1].Slug)
+
+	require.NoError(t, repo.StoreArticles(ctx, nil))
+}
+
+func testStoreArticlesDedup(t *testing.T, repo storage.ArticleRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.StoreArticles(ctx, []storage.Article{
+		{Title: "Foo", Slug: "Foo"},
+		{Title: "Duplicate", Slug: "FOO"},
+		{Title: "No slug"},
+	}))
+
+	articles := filterArticles(t, repo, storage.ArticleFilter{})
+	require.Len(t, articles, 1, "duplicates and articles without slug are skipped")
+	assert.Equal(t, "Foo", articles[0].Title, "the first duplicate is stored")
+	assert.Equal(t, "foo", articles[0].Slug, "slug is lowercased")
+}
+
+func testStoreArticlesTags(t *testing.T, repo storage.ArticleRepository) {
+	ctx := context.Background()
+	tags := func(params storage.ArticleFilter) map[string][]string {
+		res := make(map[string][]string)
+		for _, article := range filterArticles(t, repo, params) {
+			res[article.Slug] = article.Tags
+		}
+		return res
+	}
+	withTags := storage.ArticleFilter{WithTags: true}
+
+	require.NoError(t, repo.StoreArticles(ctx, []storage.Article{
+		{Title: "Foo", Slug: "foo", Tags: []string{"go", "books", "go"}},
+		{Title: "Bar", Slug: "bar"},
+	}))
+	assert.Equal(t, map[string][]string{"foo": {"books", "go"}, "bar": nil}, tags(withTags), "tags are unique and sorted")
+
+	require.NoError(t, repo.StoreArticles(ctx, []storage.Article{{Title: "Foo", Slug: "foo"}}))
+	assert.Equal(t, []string{"books", "go"}, tags(withTags)["foo"], "nil tags are kept")
+
+	require.NoError(t, repo.StoreArticles(ctx, []storage.Article{{Title: "Foo", Slug: "foo", Tags: []string{"go", "news"}}}))
+	assert.Equal(t, []string{"go", "news"}, tags(withTags)["foo"], "tags are replaced")
+
+	assert.Nil(t, tags(storage.ArticleFilter{})["foo"], "tags are loaded on demand")
+
+	require.NoError(t, repo.StoreArticles(ctx, []storage.Article{{Title: "Foo", Slug: "foo", Tags: []string{}}}))
+	assert.Nil(t, tags(withTags)["foo"], "empty tags remove all")
+}
+
+func testDeleteArticles(t *testing.T, repo storage.ArticleRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.StoreArticles(ctx, []storage.Article{
+		{Title: "Foo", Slug: "foo"},
+		{Title: "Bar", Slug: "bar"},
+	}))
+
+	require.NoError(t, repo.DeleteArticles(ctx, []storage.Article{{Slug: "FOO"}, {Slug: "missing"}, {}}))
+	articles := filterArticles(t, repo, storage.ArticleFilter{})
+	require.Len(t, articles, 1)
+	assert.Equal(t, "bar", articles[0].Slug)
+
+	require.NoError(t, repo.DeleteArticles(ctx, []storage.Article{{Slug: "foo"}}), "missing slugs are ignored")
+	require.NoError(t, repo.DeleteArticles(ctx, nil))
+}
+
+func testFilterArticlesLimit(t *testing.T, repo storage.ArticleRepository) {
+	ctx := context.Background()
+	for i := 1; i <= 5; i++ {
+		// Separate calls, so ids grow in order of slugs.
+		require.NoError(t, repo.StoreArticles(ctx, []storage.Article{{Title: "Article", Slug: fmt.Sprintf("article-%d", i)}}))
+	}
+
+	all := filterArticles(t, repo, storage.ArticleFilter{})
+	require.Len(t, all, 5)
+	assert.True(t, sort.SliceIsSorted(all, func(i, j int) bool { return all[i].ID < all[j].ID }), "ordered by id")
+	assert.Equal(t, "article-1", all[0].Slug)
+
+	tests := []struct {
+		name   string
+		params storage.ArticleFilter
+		slugs  []string
+	}{
+		{name: "limit", params: storage.ArticleFilter{Limit: 2}, slugs: []string{"article-1", "article-2"}},
+		{name: "offset", params: storage.ArticleFilter{Offset: 3}, slugs: []string{"article-4", "article-5"}},
+		{name: "limit and offset", params: storage.ArticleFilter{Limit: 2, Offset: 4}, slugs: []string{"article-5"}},
+		{name: "offset out of range", params: storage.ArticleFilter{Offset: 5}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var slugs []string
+			for _, article := range filterArticles(t, repo, tt.params) {
+				slugs = append(slugs, article.Slug)
+			}
+			assert.Equal(t, tt.slugs, slugs)
+		})
+	}
+
+	count, err := repo.CountArticles(ctx, storage.ArticleFilter{Limit: 1, Offset: 1}, storage.CountExact)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), count, "count ignores limit and offset")
+}
+
+func testFilterArticlesFields(t *testing.T, repo storage.ArticleRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.StoreArticles(ctx, []storage.Article{{Title: "Foo", Slug: "foo"}}))
+
+	articles := filterArticles(t, repo, storage.ArticleFilter{Fields: []string{storage.ArticleFieldSlug}})
+	require.Len(t, articles, 1)
+	assert.NotZero(t, articles[0].ID, "id is always loaded")
+	assert.Equal(t, "foo", articles[0].Slug)
+	assert.Empty(t, articles[0].Title)
+
+	_, err := repo.FilterArticles(ctx, storage.ArticleFilter{Fields: []string{"password"}})
+	assert.Error(t, err)
+}
+
+func testFilterArticlesExpr(t *testing.T, repo storage.ArticleRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.StoreArticles(ctx, []storage.Article{
+		{Title: "Effective Go", Slug: "effective-go", Tags: []string{"go"}},
+		{Title: "Go concurrency", Slug: "go-concurrency", Tags: []string{"go", "concurrency"}},
+		{Title: "Rust in 50% of time", Slug: "rust"},
+	}))
+
+	tests := []struct {
+		input string
+		slugs []string
+	}{
+		{input: `title~"GO"`, slugs: []string{"effective-go", "go-concurrency"}},
+		{input: `title~"50%"`, slugs: []string{"rust"}},
+		{input: `title~"_"`},
+		{input: `slug="rust" or tag="concurrency"`, slugs: []string{"go-concurrency", "rust"}},
+		{input: `tag!="concurrency"`, slugs: []string{"effective-go", "rust"}},
+		{input: `tag in ("go","rust") and not slug="effective-go"`, slugs: []string{"go-concurrency"}},
+		{input: `id>0`, slugs: []string{"effective-go", "go-concurrency", "rust"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			e, err := filter.ParseWithSchema(tt.input, storage.ArticleFilterSchema)
+			require.NoError(t, err)
+
+			var slugs []string
+			for _, article := range filterArticles(t, repo, storage.ArticleFilter{Expr: e}) {
+				slugs = append(slugs, article.Slug)
+			}
+			sort.Strings(slugs)
+			assert.Equal(t, tt.slugs, slugs)
+
+			count, err := repo.CountArticles(ctx, storage.ArticleFilter{Expr: e}, storage.CountEstimate)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(tt.slugs)), count, "small tables are counted exactly")
+		})
+	}
+}
+
+func testGetArticle(t *testing.T, repo storage.ArticleRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.StoreArticles(ctx, []storage.Article{{Title: "Foo", Slug: "foo"}}))
+
+	article, err := repo.GetArticle(ctx, "FOO")
+	require.NoError(t, err)
+	assert.NotZero(t, article.ID)
+	assert.Equal(t, "Foo", article.Title)
+	assert.Equal(t, "foo", article.Slug)
+
+	_, err = repo.GetArticle(ctx, "bar")
+	assert.ErrorIs(t, err, storage.ErrArticleNotFound)
+}
+
+func testUpdateArticle(t *testing.T, repo storage.ArticleRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.StoreArticles(ctx, []storage.Article{
+		{Title: "Foo", Slug: "foo"},
+		{Title: "Bar", Slug: "bar"},
+	}))
+	foo, err := repo.GetArticle(ctx, "foo")
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, repo.UpdateArticle(ctx, "foo", storage.Article{Title: "Foo", Slug: "BAR"}), storage.ErrArticleConflict)
+	assert.ErrorIs(t, repo.UpdateArticle(ctx, "missing", storage.Article{Title: "Missing"}), storage.ErrArticleNotFound)
+
+	require.NoError(t, repo.UpdateArticle(ctx, "FOO", storage.Article{Title: "New Foo"}))
+	article, err := repo.GetArticle(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, storage.Article{ID: foo.ID, Title: "New Foo", Slug: "foo"}, article, "slug is kept if empty")
+
+	require.NoError(t, repo.UpdateArticle(ctx, "foo", storage.Article{Title: "Baz", Slug: "Baz"}))
+	for _, slug := range []string{"baz", "foo"} {
+		article, err = repo.GetArticle(ctx, slug)
+		require.NoError(t, err)
+		assert.Equal(t, storage.Article{ID: foo.ID, Title: "Baz", Slug: "baz"}, article, "found by current and previous slug")
+	}
+
+	// Previous slug can be taken by another article, which is resolved first.
+	require.NoError(t, repo.UpdateArticle(ctx, "bar", storage.Article{Title: "Bar", Slug: "foo"}))
+	article, err = repo.GetArticle(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "Bar", article.Title)
+
+	// Slug which is taken from history of another article is removed from there.
+	require.NoError(t, repo.UpdateArticle(ctx, "baz", storage.Article{Title: "Baz", Slug: "bar"}))
+	article, err = repo.GetArticle(ctx, "bar")
+	require.NoError(t, err)
+	assert.Equal(t, foo.ID, article.ID)
+
+	require.NoError(t, repo.DeleteArticles(ctx, []storage.Article{{Slug: "bar"}}))
+	_, err = repo.GetArticle(ctx, "bar")
+	assert.ErrorIs(t, err, storage.ErrArticleNotFound)
+	_, err = repo.GetArticle(ctx, "baz")
+	assert.ErrorIs(t, err, storage.ErrArticleNotFound, "history is deleted with article")
+}
+
+func testUniqueArticleSlug(t *testing.T, repo storage.ArticleRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.StoreArticles(ctx, []storage.Article{
+		{Title: "Foo", Slug: "foo"},
+		{Title: "Foo", Slug: "foo-2"},
+		{Title: "Bar", Slug: "bar"},
+	}))
+	require.NoError(t, repo.UpdateArticle(ctx, "bar", storage.Article{Title: "Bar", Slug: "baz"}))
+
+	tests := []struct {
+		base string
+		slug string
+	}{
+		{base: "Foo", slug: "foo-3"},
+		{base: "new", slug: "new"},
+		{base: "bar", slug: "bar-2"},
+	}
+	for _, tt := range tests {
+		slug, err := repo.UniqueArticleSlug(ctx, tt.base)
+		require.NoError(t, err)
+		assert.Equal(t, tt.slug, slug, tt.base)
+	}
+}
+
+func testArticlesConcurrency(t *testing.T, repo storage.ArticleRepository) {
+	ctx := context.Background()
+	const workers = 10
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			articles := []storage.Article{
+				{Title: "Shared", Slug: "shared"},
+				{Title: "Own", Slug: fmt.Sprintf("own-%d", i)},
+			}
+			assert.NoError(t, repo.StoreArticles(ctx, articles))
+			_, err := repo.FilterArticles(ctx, storage.ArticleFilter{})
+			assert.NoError(t, err)
+			_, err = repo.GetArticle(ctx, "shared")
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	count, err := repo.CountArticles(ctx, storage.ArticleFilter{}, storage.CountExact)
+	require.NoError(t, err)
+	assert.Equal(t, int64(workers+1), count)
+}
+
+func filterArticles(t *testing.T, repo storage.ArticleRepository, params storage.ArticleFilter) []storage.Article {
+	t.Helper()
+	articles, err := repo.FilterArticles(context.Background(), params)
+	require.NoError(t, err)
+	return articles
+}
@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserExists   = errors.New("user already exists")
+)
+
+type User struct {
+	ID           int
+	Email        string
+	PasswordHash string
+}
+
+// APIKey is stored as a hash, plain key is shown only once on creation.
+type APIKey struct {
+	ID      int
+	UserID  int
+	Name    string
+	KeyHash string
+}
+
+type UserRepository interface {
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	CreateUser(ctx context.Context, user User) (User, error)
+	CreateAPIKey(ctx context.Context, key APIKey) (APIKey, error)
+}
@@ -0,0 +1,18 @@
+CREATE TABLE author (
+    id          SERIAL      PRIMARY KEY,
+    name        text        NOT NULL
+);
+
+ALTER TABLE article ADD COLUMN author_id integer REFERENCES author (id) ON DELETE SET NULL;
+
+CREATE TABLE article_tag (
+    article_id  integer     NOT NULL REFERENCES article (id) ON DELETE CASCADE,
+    tag         text        NOT NULL,
+    PRIMARY KEY (article_id, tag)
+);
+
+---- create above / drop below ----
+
+DROP TABLE article_tag;
+ALTER TABLE article DROP COLUMN author_id;
+DROP TABLE author;
@@ -0,0 +1,8 @@
+CREATE TABLE slug_history (
+    slug        text        PRIMARY KEY,
+    article_id  integer     NOT NULL REFERENCES article (id) ON DELETE CASCADE
+);
+
+---- create above / drop below ----
+
+DROP TABLE slug_history;
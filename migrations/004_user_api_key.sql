@@ -0,0 +1,19 @@
+CREATE TABLE app_user (
+    id              SERIAL      PRIMARY KEY,
+    email           text        UNIQUE NOT NULL,
+    password_hash   text        NOT NULL,
+    created_at      timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE api_key (
+    id          SERIAL      PRIMARY KEY,
+    user_id     integer     NOT NULL REFERENCES app_user (id) ON DELETE CASCADE,
+    name        text        NOT NULL,
+    key_hash    text        UNIQUE NOT NULL,
+    created_at  timestamptz NOT NULL DEFAULT now()
+);
+
+---- create above / drop below ----
+
+DROP TABLE api_key;
+DROP TABLE app_user;
@@ -0,0 +1,24 @@
+-- Caches of API instances are invalidated on any change of articles, see cache.ArticleStorage.
+CREATE FUNCTION notify_article_changed() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('article_changed', TG_TABLE_NAME);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER article_notify AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON article
+    FOR EACH STATEMENT EXECUTE PROCEDURE notify_article_changed();
+CREATE TRIGGER article_tag_notify AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON article_tag
+    FOR EACH STATEMENT EXECUTE PROCEDURE notify_article_changed();
+CREATE TRIGGER slug_history_notify AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON slug_history
+    FOR EACH STATEMENT EXECUTE PROCEDURE notify_article_changed();
+CREATE TRIGGER author_notify AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON author
+    FOR EACH STATEMENT EXECUTE PROCEDURE notify_article_changed();
+
+---- create above / drop below ----
+
+DROP TRIGGER author_notify ON author;
+DROP TRIGGER slug_history_notify ON slug_history;
+DROP TRIGGER article_tag_notify ON article_tag;
+DROP TRIGGER article_notify ON article;
+DROP FUNCTION notify_article_changed();